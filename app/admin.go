@@ -0,0 +1,146 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/alesr/urltinyizer/internal/health"
+	"github.com/alesr/urltinyizer/internal/importer"
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+// maxImportSize is the size of the largest export accepted by the import endpoint (64MB).
+const maxImportSize = 64 << 20
+
+// AdminApp serves operational endpoints, such as metrics and probes, on a listener
+// separate from the public API.
+type AdminApp struct {
+	logger  *zap.Logger
+	server  *http.Server
+	service service.Service
+}
+
+// NewAdmin creates an admin app listening on addr that serves metrics at /metrics,
+// the liveness probe at /healthz, the readiness probe at /readyz and imports
+// links from other URL shorteners at /import.
+func NewAdmin(logger *zap.Logger, addr string, metrics http.Handler, checker *health.Checker, service service.Service) *AdminApp {
+	a := &AdminApp{
+		logger:  logger,
+		service: service,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/healthz", checker.Live)
+	mux.HandleFunc("/readyz", checker.Ready)
+	mux.HandleFunc("/import", a.importURLs)
+
+	a.server = &http.Server{
+		ReadHeaderTimeout: time.Duration(5) * time.Second,
+		WriteTimeout:      time.Duration(10) * time.Second,
+		Addr:              addr,
+		Handler:           mux,
+	}
+	return a
+}
+
+// Run starts the admin server and listens for cancellation signals.
+func (a *AdminApp) Run(ctx context.Context) error {
+	a.logger.Info("starting admin server", zap.String("addr", a.server.Addr))
+
+	go func() {
+		<-ctx.Done()
+		if err := a.server.Shutdown(context.Background()); err != nil {
+			a.logger.Error("failed to shutdown admin server", zap.Error(err))
+		}
+	}()
+
+	if err := a.server.ListenAndServe(); err != http.ErrServerClosed {
+		return fmt.Errorf("could not start admin server: %w", err)
+	}
+	return nil
+}
+
+// importURLs imports the links of a Bitly or YOURLS export posted in the
+// request body, in the format given by the format query parameter (csv by
+// default). With
+// dry_run=true it only reports what the import would do, and owner_key_id
+// makes the links owned by that API key.
+func (a *AdminApp) importURLs(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+
+	var dryRun bool
+	if s := query.Get("dry_run"); s != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, "invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var ownerKeyID int64
+	if s := query.Get("owner_key_id"); s != "" {
+		var err error
+		if ownerKeyID, err = strconv.ParseInt(s, 10, 64); err != nil || ownerKeyID <= 0 {
+			http.Error(w, "invalid owner_key_id", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Large imports can take longer than the server write timeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		a.logger.Warn("could not disable write deadline", zap.Error(err))
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = importer.FormatCSV
+	}
+
+	records, err := importer.Read(http.MaxBytesReader(w, req.Body, maxImportSize), format)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "export is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := a.service.ImportURLs(req.Context(), records, ownerKeyID, dryRun)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			http.Error(w, "owner api key not found", http.StatusBadRequest)
+			return
+		}
+		a.logger.Error("could not import urls", zap.Error(err))
+		http.Error(w, "could not import urls", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(ImportResponse{
+		DryRun:    report.DryRun,
+		Imported:  report.Imported,
+		Unchanged: report.Unchanged,
+		Conflicts: newImportIssueResponses(report.Conflicts),
+		Invalid:   newImportIssueResponses(report.Invalid),
+	}); err != nil {
+		a.logger.Error("could not encode response", zap.Error(err))
+	}
+}
@@ -4,11 +4,28 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/qrcode"
+	"github.com/alesr/urltinyizer/internal/service"
 )
 
 const (
 	// maxLongURLSize is the maximum size of a long URL (2MB)
 	maxLongURLSize = 2048 * 1024
+
+	// maxPasswordSize is the maximum size of a link password.
+	maxPasswordSize = 72
+
+	// QR code rendering defaults and bounds.
+	defaultQRSize   = 256
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+
+	// defaultTopLinksWindow is the window of the top links leaderboard when none is given.
+	defaultTopLinksWindow = 24 * time.Hour
 )
 
 // App is an interface that defines the methods that an app should implement.
@@ -17,11 +34,31 @@ type App interface {
 }
 
 type CreateShortURLRequest struct {
-	LongURL string `json:"long_url"`
+	LongURL        string     `json:"long_url"`
+	RedirectStatus int        `json:"redirect_status,omitempty"`
+	Password       string     `json:"password,omitempty"`
+	NotBefore      *time.Time `json:"not_before,omitempty"`
+	NotAfter       *time.Time `json:"not_after,omitempty"`
 }
 
 func (r *CreateShortURLRequest) Validate() error {
-	return validateURL(r.LongURL)
+	if err := validateURL(r.LongURL); err != nil {
+		return err
+	}
+
+	if r.RedirectStatus != 0 && !service.ValidRedirectStatus(r.RedirectStatus) {
+		return fmt.Errorf("invalid redirect status: %d", r.RedirectStatus)
+	}
+
+	// bcrypt ignores anything past 72 bytes.
+	if len(r.Password) > maxPasswordSize {
+		return fmt.Errorf("password is too long")
+	}
+
+	if r.NotBefore != nil && r.NotAfter != nil && !r.NotAfter.After(*r.NotBefore) {
+		return errors.New("not_after must be after not_before")
+	}
+	return nil
 }
 
 type CreateShortURLResponse struct {
@@ -34,6 +71,75 @@ func (r *RedirectToLongURLRequest) Validate() error {
 	return validateURL(string(*r))
 }
 
+type CreateRuleRequest struct {
+	Kind      string `json:"kind"`
+	Condition string `json:"condition"`
+	TargetURL string `json:"target_url"`
+	Priority  int    `json:"priority"`
+}
+
+func (r *CreateRuleRequest) Validate() error {
+	if r.Kind == "" {
+		return errors.New("kind is required")
+	}
+
+	if r.Condition == "" {
+		return errors.New("condition is required")
+	}
+	return validateURL(r.TargetURL)
+}
+
+type RuleResponse struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Condition string    `json:"condition"`
+	TargetURL string    `json:"target_url"`
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newRuleResponse(rule service.Rule) RuleResponse {
+	return RuleResponse{
+		ID:        rule.ID,
+		Kind:      rule.Kind,
+		Condition: rule.Condition,
+		TargetURL: rule.TargetURL,
+		Priority:  rule.Priority,
+		CreatedAt: rule.CreatedAt,
+	}
+}
+
+type GetQRCodeRequest struct {
+	ShortURL string
+	Format   string
+	Size     int
+	Level    string
+	Margin   int
+}
+
+func (r *GetQRCodeRequest) Validate() error {
+	if err := validateURL(r.ShortURL); err != nil {
+		return err
+	}
+
+	if r.Format != "png" && r.Format != "svg" {
+		return fmt.Errorf("invalid format: %q", r.Format)
+	}
+
+	if r.Size < minQRSize || r.Size > maxQRSize {
+		return fmt.Errorf("size must be between %d and %d", minQRSize, maxQRSize)
+	}
+
+	if _, err := qrcode.ParseLevel(r.Level); err != nil {
+		return fmt.Errorf("invalid level: %w", err)
+	}
+
+	if r.Margin < 0 || r.Margin > maxQRMargin {
+		return fmt.Errorf("margin must be between 0 and %d", maxQRMargin)
+	}
+	return nil
+}
+
 type GetStatsRequest string
 
 func (r *GetStatsRequest) Validate() error {
@@ -41,10 +147,146 @@ func (r *GetStatsRequest) Validate() error {
 }
 
 type GetStatsResponse struct {
+	ShortURL            string                  `json:"short_url"`
+	Hits                int                     `json:"hits"`
+	BotHits             int                     `json:"bot_hits"`
+	UniqueVisitors      int                     `json:"unique_visitors"`
+	DailyUniqueVisitors []DailyVisitorsResponse `json:"daily_unique_visitors"`
+	Variants            []VariantResponse       `json:"variants,omitempty"`
+	Referrers           []SourceCountResponse   `json:"referrers"`
+	UTMSources          []SourceCountResponse   `json:"utm_sources"`
+	UserAgentFamilies   []SourceCountResponse   `json:"user_agent_families"`
+	Series              []BucketResponse        `json:"series,omitempty"`
+}
+
+type BucketResponse struct {
+	Start string `json:"start"`
+	Hits  int    `json:"hits"`
+}
+
+type SourceCountResponse struct {
+	Value string `json:"value"`
+	Hits  int    `json:"hits"`
+}
+
+type DailyVisitorsResponse struct {
+	Day            string `json:"day"`
+	UniqueVisitors int    `json:"unique_visitors"`
+}
+
+type TopLinksResponse struct {
+	Window string             `json:"window"`
+	Links  []LinkHitsResponse `json:"links"`
+}
+
+type LinkHitsResponse struct {
 	ShortURL string `json:"short_url"`
 	Hits     int    `json:"hits"`
 }
 
+type ImportResponse struct {
+	DryRun    bool                  `json:"dry_run"`
+	Imported  int                   `json:"imported"`
+	Unchanged int                   `json:"unchanged"`
+	Conflicts []ImportIssueResponse `json:"conflicts"`
+	Invalid   []ImportIssueResponse `json:"invalid"`
+}
+
+type ImportIssueResponse struct {
+	Position int    `json:"position"`
+	Code     string `json:"code"`
+	LongURL  string `json:"long_url"`
+	Reason   string `json:"reason"`
+}
+
+func newImportIssueResponses(issues []service.ImportIssue) []ImportIssueResponse {
+	resp := make([]ImportIssueResponse, 0, len(issues))
+	for _, issue := range issues {
+		resp = append(resp, ImportIssueResponse{
+			Position: issue.Position,
+			Code:     issue.Code,
+			LongURL:  issue.LongURL,
+			Reason:   issue.Reason,
+		})
+	}
+	return resp
+}
+
+type VariantRequest struct {
+	TargetURL string `json:"target_url"`
+	Weight    int    `json:"weight"`
+}
+
+type SetVariantsRequest struct {
+	Sticky   bool             `json:"sticky"`
+	Variants []VariantRequest `json:"variants"`
+}
+
+func (r *SetVariantsRequest) Validate() error {
+	for _, v := range r.Variants {
+		if err := validateURL(v.TargetURL); err != nil {
+			return err
+		}
+
+		if v.Weight <= 0 {
+			return fmt.Errorf("weight must be positive")
+		}
+	}
+	return nil
+}
+
+type VariantResponse struct {
+	ID        int64  `json:"id"`
+	TargetURL string `json:"target_url"`
+	Weight    int    `json:"weight"`
+	Hits      int    `json:"hits"`
+}
+
+type VariantsResponse struct {
+	Sticky   bool              `json:"sticky"`
+	Variants []VariantResponse `json:"variants"`
+}
+
+func newVariantResponses(variants []service.Variant) []VariantResponse {
+	resp := make([]VariantResponse, 0, len(variants))
+	for _, v := range variants {
+		resp = append(resp, VariantResponse{
+			ID:        v.ID,
+			TargetURL: v.TargetURL,
+			Weight:    v.Weight,
+			Hits:      v.Hits,
+		})
+	}
+	return resp
+}
+
+func newDailyVisitorsResponses(days []service.DailyVisitors) []DailyVisitorsResponse {
+	resp := make([]DailyVisitorsResponse, 0, len(days))
+	for _, d := range days {
+		resp = append(resp, DailyVisitorsResponse{
+			Day:            d.Day.Format(time.DateOnly),
+			UniqueVisitors: d.UniqueVisitors,
+		})
+	}
+	return resp
+}
+
+func newSourceCountResponses(sources []service.SourceCount) []SourceCountResponse {
+	resp := make([]SourceCountResponse, 0, len(sources))
+	for _, s := range sources {
+		resp = append(resp, SourceCountResponse{Value: s.Value, Hits: s.Hits})
+	}
+	return resp
+}
+
+func newBucketResponses(buckets []service.Bucket) []BucketResponse {
+	resp := make([]BucketResponse, 0, len(buckets))
+	for _, b := range buckets {
+		resp = append(resp, BucketResponse{Start: b.Start.Format(time.RFC3339), Hits: b.Hits})
+	}
+	return resp
+}
+
 func validateURL(u string) error {
 	if len(u) == 0 {
 		return errors.New("url is required")
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/alesr/urltinyizer/internal/netutil"
+)
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR networks.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		network, err := netutil.ParseNetwork(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// clientIP returns the IP address of the client that sent req. When the
+// request comes from a trusted proxy, X-Forwarded-For is walked from the
+// right and the first address that is not a trusted proxy is returned.
+func (app *RESTApp) clientIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !app.trustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			break
+		}
+
+		ip = hop
+		if !app.trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (app *RESTApp) trustedProxy(ip net.IP) bool {
+	for _, network := range app.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
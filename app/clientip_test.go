@@ -0,0 +1,184 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		given       string
+		expected    []string
+		expectedErr bool
+	}{
+		{name: "no trusted proxies", given: "", expected: nil},
+		{name: "ip addresses", given: "10.0.0.1, 2001:db8::1", expected: []string{"10.0.0.1/32", "2001:db8::1/128"}},
+		{name: "networks", given: "10.0.0.0/8,fd00::/8", expected: []string{"10.0.0.0/8", "fd00::/8"}},
+		{name: "blank entries", given: " ,10.0.0.0/8,, ", expected: []string{"10.0.0.0/8"}},
+		{name: "invalid cidr", given: "10.0.0.0/8,10.0.0.0/40", expectedErr: true},
+		{name: "invalid ip address", given: "proxy.local", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			proxies, err := ParseTrustedProxies(tc.given)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var observed []string
+			for _, proxy := range proxies {
+				observed = append(observed, proxy.String())
+			}
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestTrustedProxy(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := ParseTrustedProxies("10.0.0.0/8,2001:db8::/32")
+	require.NoError(t, err)
+
+	app := &RESTApp{proxies: proxies}
+
+	testCases := []struct {
+		name     string
+		given    string
+		expected bool
+	}{
+		{name: "ipv4 in network", given: "10.1.2.3", expected: true},
+		{name: "ipv4 outside network", given: "192.0.2.1", expected: false},
+		{name: "ipv6 in network", given: "2001:db8::7", expected: true},
+		{name: "ipv6 outside network", given: "2001:db9::7", expected: false},
+		{name: "ipv4-mapped ipv6 in network", given: "::ffff:10.1.2.3", expected: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, app.trustedProxy(net.ParseIP(tc.given)))
+		})
+	}
+
+	t.Run("no trusted proxies", func(t *testing.T) {
+		t.Parallel()
+
+		assert.False(t, (&RESTApp{}).trustedProxy(net.ParseIP("10.1.2.3")))
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		proxies      string
+		remoteAddr   string
+		forwardedFor []string
+		expected     string
+	}{
+		{
+			name:         "no trusted proxies",
+			remoteAddr:   "192.0.2.1:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "192.0.2.1",
+		},
+		{
+			name:         "untrusted peer",
+			proxies:      "10.0.0.0/8",
+			remoteAddr:   "192.0.2.1:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "192.0.2.1",
+		},
+		{
+			name:         "trusted proxy",
+			proxies:      "10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.7"},
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "spoofed leading entry",
+			proxies:      "10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"203.0.113.66, 198.51.100.7"},
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "several trusted hops",
+			proxies:      "10.0.0.0/8,172.16.0.1",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"203.0.113.66, 198.51.100.7, 172.16.0.1", "10.0.0.2"},
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "only trusted hops",
+			proxies:      "10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"10.0.0.3, 10.0.0.2"},
+			expected:     "10.0.0.3",
+		},
+		{
+			name:         "invalid hop",
+			proxies:      "10.0.0.0/8",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"198.51.100.7, unknown"},
+			expected:     "10.0.0.1",
+		},
+		{
+			name:       "trusted proxy without forwarded for",
+			proxies:    "10.0.0.0/8",
+			remoteAddr: "10.0.0.1:1234",
+			expected:   "10.0.0.1",
+		},
+		{
+			name:         "ipv6",
+			proxies:      "2001:db8::/32",
+			remoteAddr:   "[2001:db8::1]:1234",
+			forwardedFor: []string{"2001:db9::7, 2001:db8::2"},
+			expected:     "2001:db9::7",
+		},
+		{
+			name:       "remote address without port",
+			remoteAddr: "192.0.2.1",
+			expected:   "192.0.2.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			proxies, err := ParseTrustedProxies(tc.proxies)
+			require.NoError(t, err)
+
+			app := &RESTApp{proxies: proxies}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			assert.Equal(t, tc.expected, app.clientIP(req).String())
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/alesr/urltinyizer/internal/metrics"
+	"github.com/alesr/urltinyizer/internal/requestid"
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+// tracerName is the instrumentation name of the HTTP server spans.
+const tracerName = "github.com/alesr/urltinyizer/app"
+
+// requestID propagates a valid X-Request-ID from the client, or assigns a
+// new one, stores it in the request context and echoes it in the response.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, req.WithContext(requestid.NewContext(req.Context(), id)))
+	})
+}
+
+// traceRequest starts a server span for each request, continuing the trace
+// from the W3C traceparent header when the client sends one.
+func (app *RESTApp) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+		ctx, span := app.tracer.Start(ctx, req.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(req.Method),
+				semconv.HTTPTarget(req.URL.RequestURI()),
+				semconv.HTTPUserAgent(req.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+
+		next.ServeHTTP(ww, req.WithContext(ctx))
+
+		route, status := metrics.RoutePattern(req), responseStatus(ww)
+
+		// The route is only known once chi has matched the request.
+		span.SetName(req.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPStatusCode(status))
+
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// accessLog logs one line per request once it has been served.
+func (app *RESTApp) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+
+		next.ServeHTTP(ww, req)
+
+		clientIP := ""
+		if ip := app.clientIP(req); ip != nil {
+			clientIP = ip.String()
+		}
+
+		fields := []zap.Field{
+			zap.String("method", req.Method),
+			zap.String("route", metrics.RoutePattern(req)),
+			zap.Int("status", responseStatus(ww)),
+			zap.Int("bytes", ww.BytesWritten()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", clientIP),
+		}
+
+		if sc := trace.SpanContextFromContext(req.Context()); sc.IsValid() {
+			fields = append(fields, zap.String("trace_id", sc.TraceID().String()))
+		}
+
+		app.log(req.Context()).Info("request", fields...)
+	})
+}
+
+// responseStatus returns the status written to ww, which is 200 when the
+// handler wrote a body without calling WriteHeader.
+func responseStatus(ww middleware.WrapResponseWriter) int {
+	if status := ww.Status(); status != 0 {
+		return status
+	}
+	return http.StatusOK
+}
+
+// apiKeyContextKey is the request context key of the authenticated API key.
+type apiKeyContextKey struct{}
+
+// authenticate authenticates requests sending an API key as a bearer token
+// in the Authorization header and stores the key in the request context.
+// Requests without the header are passed on unauthenticated.
+func (app *RESTApp) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header := req.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			http.Error(w, "invalid authorization header", http.StatusUnauthorized)
+			return
+		}
+
+		key, err := app.service.AuthenticateAPIKey(req.Context(), strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				http.Error(w, "invalid api key", http.StatusUnauthorized)
+				return
+			}
+			app.log(req.Context()).Error("could not authenticate api key", zap.Error(err))
+			http.Error(w, "could not authenticate api key", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+// requireOwner lets through requests authenticated with an API key, for
+// short URLs that were created with that key. Short URLs without an owner
+// cannot be claimed by any key. It guards the routes that show or change
+// where a short URL sends its visitors, as their targets may be hidden
+// behind a password or an activation window.
+func (app *RESTApp) requireOwner(next http.Handler) http.Handler {
+	return app.authenticate(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key, ok := apiKeyFromContext(req.Context())
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "api key required", http.StatusUnauthorized)
+			return
+		}
+
+		shortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			http.Error(w, "could not unescape short URL", http.StatusBadRequest)
+			return
+		}
+
+		link, err := app.service.GetURL(req.Context(), shortURL)
+		if err != nil {
+			// Unknown short URLs are reported by the handler.
+			if errors.Is(err, service.ErrNotFound) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			app.log(req.Context()).Error("could not get short URL", zap.Error(err))
+			http.Error(w, "could not get short URL", http.StatusInternalServerError)
+			return
+		}
+
+		if link.OwnerKeyID == 0 {
+			http.Error(w, "short URL has no owner", http.StatusForbidden)
+			return
+		}
+
+		if link.OwnerKeyID != key.ID {
+			http.Error(w, "short URL belongs to another api key", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, req)
+	}))
+}
+
+// apiKeyFromContext returns the API key the request carrying ctx was
+// authenticated with, if any.
+func apiKeyFromContext(ctx context.Context) (service.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(service.APIKey)
+	return key, ok
+}
+
+// log returns the app logger annotated with the request ID carried by ctx.
+func (app *RESTApp) log(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, app.logger)
+}
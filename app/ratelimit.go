@@ -0,0 +1,72 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// failureLimiter tracks failed attempts per key within a fixed window and
+// blocks a key once it reaches the maximum number of failures.
+type failureLimiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	failures    map[string]*failureWindow
+}
+
+type failureWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+func newFailureLimiter(maxFailures int, window time.Duration) *failureLimiter {
+	return &failureLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		failures:    make(map[string]*failureWindow),
+	}
+}
+
+// Allow reports whether key may make another attempt and, if so, counts it
+// as failed until Release is called. Counting the attempt before it is made
+// keeps parallel requests from all passing the check.
+func (l *failureLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop expired windows so the map does not grow with one-off clients.
+	for k, fw := range l.failures {
+		if now.After(fw.resetAt) {
+			delete(l.failures, k)
+		}
+	}
+
+	fw, ok := l.failures[key]
+	if !ok {
+		fw = &failureWindow{resetAt: now.Add(l.window)}
+		l.failures[key] = fw
+	}
+
+	if fw.count >= l.maxFailures {
+		return false
+	}
+	fw.count++
+	return true
+}
+
+// Release uncounts an attempt of key allowed by Allow that did not fail.
+func (l *failureLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fw, ok := l.failures[key]
+	if !ok {
+		return
+	}
+
+	if fw.count > 0 {
+		fw.count--
+	}
+}
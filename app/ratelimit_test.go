@@ -0,0 +1,73 @@
+package app
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("blocks after max failures", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newFailureLimiter(2, time.Minute)
+
+		require.True(t, limiter.Allow("1.2.3.4"))
+		require.True(t, limiter.Allow("1.2.3.4"))
+		require.False(t, limiter.Allow("1.2.3.4"))
+
+		require.True(t, limiter.Allow("5.6.7.8"))
+	})
+
+	t.Run("released attempts are not counted", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newFailureLimiter(2, time.Minute)
+
+		for i := 0; i < 5; i++ {
+			require.True(t, limiter.Allow("1.2.3.4"))
+			limiter.Release("1.2.3.4")
+		}
+	})
+
+	t.Run("parallel attempts are limited", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newFailureLimiter(5, time.Minute)
+
+		var (
+			wg      sync.WaitGroup
+			allowed int32
+		)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if limiter.Allow("1.2.3.4") {
+					atomic.AddInt32(&allowed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, int32(5), allowed)
+	})
+
+	t.Run("window resets", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := newFailureLimiter(1, time.Millisecond)
+
+		require.True(t, limiter.Allow("1.2.3.4"))
+		require.False(t, limiter.Allow("1.2.3.4"))
+
+		time.Sleep(5 * time.Millisecond)
+
+		require.True(t, limiter.Allow("1.2.3.4"))
+	})
+}
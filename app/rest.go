@@ -2,59 +2,237 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/alesr/urltinyizer/internal/export"
+	"github.com/alesr/urltinyizer/internal/metrics"
+	"github.com/alesr/urltinyizer/internal/qrcode"
 	"github.com/alesr/urltinyizer/internal/service"
 	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const (
+	// permanentRedirectMaxAge is how long browsers and CDNs may cache permanent redirects.
+	permanentRedirectMaxAge = 24 * time.Hour
+
+	// qrCacheSize is the number of rendered QR codes kept in memory.
+	qrCacheSize = 1024
+
+	// Defaults for rate limiting failed unlock attempts of password-protected links.
+	defaultUnlockMaxFailures = 5
+	defaultUnlockWindow      = 15 * time.Minute
+
+	unlockCookiePrefix  = "unlock_"
+	variantCookiePrefix = "variant_"
+
+	// variantCookieMaxAge is how long a visitor keeps a sticky variant.
+	variantCookieMaxAge = 30 * 24 * time.Hour
+
+	// exportFlushEvery is the number of exported short URLs written between flushes.
+	exportFlushEvery = 1000
+
+	// Defaults for the HTTP server.
+	defaultAddr                = ":8080"
+	defaultReadTimeout         = 5 * time.Second
+	defaultReadHeaderTimeout   = 5 * time.Second
+	defaultWriteTimeout        = 10 * time.Second
+	defaultShutdownGracePeriod = 15 * time.Second
 )
 
 // RESTApp is an app that implements the App interface.
 type RESTApp struct {
-	logger  *zap.Logger
-	server  *http.Server
-	service service.Service
+	logger        *zap.Logger
+	server        *http.Server
+	service       service.Service
+	qrCache       *qrcode.Cache
+	unlockLimiter *failureLimiter
+	inactivePage  []byte
+	proxies       []*net.IPNet
+	metrics       *metrics.Metrics
+	tracer        trace.Tracer
+	h2c           bool
+	shutdownGrace time.Duration
+}
+
+// Option configures optional behaviour of RESTApp.
+type Option func(*RESTApp)
+
+// WithUnlockRateLimit limits failed password attempts per client IP to
+// maxFailures within window.
+func WithUnlockRateLimit(maxFailures int, window time.Duration) Option {
+	return func(app *RESTApp) {
+		app.unlockLimiter = newFailureLimiter(maxFailures, window)
+	}
+}
+
+// WithInactivePage sets the HTML page served for links used before their
+// activation time. Without it such links respond with 404 Not Found.
+func WithInactivePage(page []byte) Option {
+	return func(app *RESTApp) {
+		app.inactivePage = page
+	}
+}
+
+// WithTrustedProxies sets the networks whose X-Forwarded-For header is
+// trusted when determining the client IP address.
+func WithTrustedProxies(proxies []*net.IPNet) Option {
+	return func(app *RESTApp) {
+		app.proxies = proxies
+	}
+}
+
+// WithMetrics sets the metrics the app reports requests, redirects and created links to.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(app *RESTApp) {
+		app.metrics = m
+	}
+}
+
+// WithAddr sets the address the server listens on.
+func WithAddr(addr string) Option {
+	return func(app *RESTApp) {
+		app.server.Addr = addr
+	}
+}
+
+// WithTimeouts sets the read, read header, write and idle timeouts of the
+// server. A zero idle timeout falls back to the read timeout.
+func WithTimeouts(read, readHeader, write, idle time.Duration) Option {
+	return func(app *RESTApp) {
+		app.server.ReadTimeout = read
+		app.server.ReadHeaderTimeout = readHeader
+		app.server.WriteTimeout = write
+		app.server.IdleTimeout = idle
+	}
+}
+
+// WithTLS serves HTTPS with the certificate returned by getCertificate,
+// so certificates can be replaced without restarting the server.
+func WithTLS(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) Option {
+	return func(app *RESTApp) {
+		app.server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: getCertificate,
+		}
+	}
+}
+
+// WithH2C enables HTTP/2 without TLS, for deployments where TLS is
+// terminated by a proxy that speaks HTTP/2 to the app. HTTPS servers
+// negotiate HTTP/2 regardless.
+func WithH2C() Option {
+	return func(app *RESTApp) {
+		app.h2c = true
+	}
+}
+
+// WithShutdownGracePeriod sets how long Run waits for in-flight requests
+// to finish once its context is cancelled.
+func WithShutdownGracePeriod(d time.Duration) Option {
+	return func(app *RESTApp) {
+		app.shutdownGrace = d
+	}
 }
 
 // NewREST creates a new REST app.
-func NewREST(logger *zap.Logger, router *chi.Mux, service service.Service) *RESTApp {
-	return &RESTApp{
+func NewREST(logger *zap.Logger, router *chi.Mux, service service.Service, opts ...Option) *RESTApp {
+	app := &RESTApp{
 		logger: logger,
 		server: &http.Server{
-			ReadTimeout:       time.Duration(5) * time.Second,
-			ReadHeaderTimeout: time.Duration(5) * time.Second,
-			WriteTimeout:      time.Duration(10) * time.Second,
-			Addr:              ":8080",
+			ReadTimeout:       defaultReadTimeout,
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			WriteTimeout:      defaultWriteTimeout,
+			Addr:              defaultAddr,
 			Handler:           router,
 		},
-		service: service,
+		service:       service,
+		qrCache:       qrcode.NewCache(qrCacheSize),
+		unlockLimiter: newFailureLimiter(defaultUnlockMaxFailures, defaultUnlockWindow),
+		metrics:       metrics.New(),
+		tracer:        otel.Tracer(tracerName),
+		shutdownGrace: defaultShutdownGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(app)
 	}
+	return app
 }
 
 func (app *RESTApp) RegisterRoutes() {
-	app.server.Handler.(*chi.Mux).Post("/shorten", app.createShortURL())
+	app.server.Handler.(*chi.Mux).Use(requestID, app.traceRequest, app.accessLog, app.metrics.Middleware)
+
+	app.server.Handler.(*chi.Mux).With(app.authenticate).Post("/shorten", app.createShortURL())
 	app.server.Handler.(*chi.Mux).Get("/{shortURL}", app.redirectToLongURL())
+	app.server.Handler.(*chi.Mux).Post("/{shortURL}", app.unlockURL())
+	app.server.Handler.(*chi.Mux).Get("/{shortURL}+", app.previewURL())
 	app.server.Handler.(*chi.Mux).Get("/{shortURL}/stats", app.getStats())
+	app.server.Handler.(*chi.Mux).Get("/{shortURL}/qr", app.getQRCode())
+	app.server.Handler.(*chi.Mux).With(app.requireOwner).Get("/{shortURL}/rules", app.listRules())
+	app.server.Handler.(*chi.Mux).With(app.requireOwner).Post("/{shortURL}/rules", app.createRule())
+	app.server.Handler.(*chi.Mux).With(app.requireOwner).Delete("/{shortURL}/rules/{ruleID}", app.deleteRule())
+	app.server.Handler.(*chi.Mux).With(app.requireOwner).Get("/{shortURL}/variants", app.getVariants())
+	app.server.Handler.(*chi.Mux).With(app.requireOwner).Put("/{shortURL}/variants", app.setVariants())
+	app.server.Handler.(*chi.Mux).Get("/api/stats/top", app.topLinks())
+	app.server.Handler.(*chi.Mux).With(app.authenticate).Get("/api/export", app.exportURLs())
 }
 
 // Run starts the REST API server and listens for cancellation signals.
+// Once ctx is cancelled, in-flight requests get the shutdown grace period
+// to finish before Run returns.
 func (app *RESTApp) Run(ctx context.Context) error {
-	app.logger.Info("starting server on port 8080")
+	if app.h2c && app.server.TLSConfig == nil {
+		app.server.Handler = h2c.NewHandler(app.server.Handler, &http2.Server{})
+	}
+
+	app.logger.Info("starting server",
+		zap.String("addr", app.server.Addr),
+		zap.Bool("tls", app.server.TLSConfig != nil),
+		zap.Bool("h2c", app.h2c && app.server.TLSConfig == nil),
+	)
 
+	shutdownErr := make(chan error, 1)
 	go func() {
 		<-ctx.Done()
-		if err := app.server.Shutdown(ctx); err != nil {
-			app.logger.Error("failed to shutdown server", zap.Error(err))
-		}
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), app.shutdownGrace)
+		defer cancel()
+
+		shutdownErr <- app.server.Shutdown(shutdownCtx)
 	}()
 
-	if err := app.server.ListenAndServe(); err != http.ErrServerClosed {
+	var err error
+	if app.server.TLSConfig != nil {
+		// The certificate comes from TLSConfig.GetCertificate.
+		err = app.server.ListenAndServeTLS("", "")
+	} else {
+		err = app.server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		return fmt.Errorf("could not start server: %w", err)
 	}
+
+	// The server was closed by Terminate rather than by cancelling ctx.
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	if err := <-shutdownErr; err != nil {
+		return fmt.Errorf("could not shutdown server gracefully: %w", err)
+	}
 	return nil
 }
 
@@ -67,28 +245,46 @@ func (app *RESTApp) createShortURL() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		var reqPayload CreateShortURLRequest
 		if err := json.NewDecoder(req.Body).Decode(&reqPayload); err != nil {
-			app.logger.Error("could not decode request body", zap.Error(err))
+			app.log(req.Context()).Error("could not decode request body", zap.Error(err))
 			http.Error(w, "invalid request body", http.StatusBadRequest)
 			return
 		}
 
 		if err := reqPayload.Validate(); err != nil {
-			app.logger.Error("invalid request body", zap.Error(err))
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		short, err := app.service.CreateShortURL(req.Context(), reqPayload.LongURL)
+		var ownerKeyID int64
+		if key, ok := apiKeyFromContext(req.Context()); ok {
+			ownerKeyID = key.ID
+		}
+
+		short, err := app.service.CreateShortURL(req.Context(), service.CreateShortURLInput{
+			LongURL:        reqPayload.LongURL,
+			RedirectStatus: reqPayload.RedirectStatus,
+			Password:       reqPayload.Password,
+			NotBefore:      reqPayload.NotBefore,
+			NotAfter:       reqPayload.NotAfter,
+			OwnerKeyID:     ownerKeyID,
+		})
 		if err != nil {
-			app.logger.Error("could not create short URL", zap.Error(err))
+			if errors.Is(err, service.ErrInvalidURL) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			app.log(req.Context()).Error("could not create short URL", zap.Error(err))
 			http.Error(w, "could not create short URL", http.StatusInternalServerError)
 			return
 		}
 
+		app.metrics.ObserveLinkCreated()
+
 		w.Header().Set("Content-Type", "application/json")
 
 		if err := json.NewEncoder(w).Encode(CreateShortURLResponse{ShortURL: short}); err != nil {
-			app.logger.Error("could not encode response", zap.Error(err))
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
 			http.Error(w, "could not encode response", http.StatusInternalServerError)
 			return
 		}
@@ -100,7 +296,7 @@ func (app *RESTApp) redirectToLongURL() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
 		if err != nil {
-			app.logger.Error("could not unescape short URL", zap.Error(err))
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
 			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
 			return
 		}
@@ -108,18 +304,572 @@ func (app *RESTApp) redirectToLongURL() http.HandlerFunc {
 		shortURL := RedirectToLongURLRequest(escapedShortURL)
 
 		if err := shortURL.Validate(); err != nil {
-			app.logger.Error("invalid request body", zap.Error(err))
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		longURL, err := app.service.RedirectToLongURL(req.Context(), string(shortURL))
+		redirectInput := service.RedirectInput{
+			ShortURL:       string(shortURL),
+			UserAgent:      req.UserAgent(),
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+			ClientIP:       app.clientIP(req),
+			Referrer:       req.Referer(),
+			UTMSource:      req.URL.Query().Get("utm_source"),
+		}
+
+		if cookie, err := req.Cookie(linkCookieName(unlockCookiePrefix, string(shortURL))); err == nil {
+			redirectInput.UnlockToken = cookie.Value
+		}
+
+		if cookie, err := req.Cookie(linkCookieName(variantCookiePrefix, string(shortURL))); err == nil {
+			redirectInput.VariantID, _ = strconv.ParseInt(cookie.Value, 10, 64)
+		}
+
+		redirect, err := app.service.RedirectToLongURL(req.Context(), redirectInput)
+		app.metrics.ObserveRedirect(redirectOutcome(err))
+
 		if err != nil {
-			app.logger.Error("could not redirect to long URL", zap.Error(err))
+			if app.writeUnavailable(w, err) {
+				return
+			}
+			if errors.Is(err, service.ErrPasswordRequired) {
+				app.renderUnlockPage(w, http.StatusUnauthorized, "")
+				return
+			}
+			app.log(req.Context()).Error("could not redirect to long URL", zap.Error(err))
 			http.Error(w, "could not redirect to long URL", http.StatusInternalServerError)
 			return
 		}
-		http.Redirect(w, req, longURL, http.StatusFound)
+
+		if redirect.StickyVariant {
+			http.SetCookie(w, &http.Cookie{
+				Name:     linkCookieName(variantCookiePrefix, string(shortURL)),
+				Value:    strconv.FormatInt(redirect.VariantID, 10),
+				Path:     "/",
+				MaxAge:   int(variantCookieMaxAge.Seconds()),
+				HttpOnly: true,
+				Secure:   req.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		// Variants are picked on every visit, rules pick a target for each
+		// visitor and unlocked links are only for visitors holding the unlock
+		// cookie, so their redirects must not be cached.
+		if redirect.VariantID != 0 || redirect.RulesEvaluated || redirect.PasswordProtected {
+			w.Header().Set("Cache-Control", "private, no-store")
+		} else if redirect.StatusCode == http.StatusMovedPermanently || redirect.StatusCode == http.StatusPermanentRedirect {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(permanentRedirectMaxAge.Seconds())))
+		}
+		http.Redirect(w, req, redirect.LongURL, redirect.StatusCode)
+	}
+}
+
+// UnlockURL checks the password of a protected short URL and, when it matches,
+// remembers the unlock in a signed cookie and redirects back to the short URL.
+func (app *RESTApp) unlockURL() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := RedirectToLongURLRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ip := app.clientIP(req).String()
+
+		if !app.unlockLimiter.Allow(ip) {
+			app.renderUnlockPage(w, http.StatusTooManyRequests, "Too many failed attempts, try again later.")
+			return
+		}
+
+		token, err := app.service.UnlockURL(req.Context(), string(shortURL), req.PostFormValue("password"))
+		if !errors.Is(err, service.ErrInvalidPassword) {
+			app.unlockLimiter.Release(ip)
+		}
+
+		if err != nil {
+			if app.writeUnavailable(w, err) {
+				return
+			}
+			if errors.Is(err, service.ErrInvalidPassword) {
+				app.renderUnlockPage(w, http.StatusUnauthorized, "Incorrect password.")
+				return
+			}
+			app.log(req.Context()).Error("could not unlock short URL", zap.Error(err))
+			http.Error(w, "could not unlock short URL", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     linkCookieName(unlockCookiePrefix, string(shortURL)),
+			Value:    token.Value,
+			Path:     "/",
+			Expires:  token.ExpiresAt,
+			HttpOnly: true,
+			Secure:   req.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, req, req.URL.RequestURI(), http.StatusSeeOther)
+	}
+}
+
+// writeUnavailable responds to errors about short URLs that cannot be used,
+// reporting whether err was one of them.
+func (app *RESTApp) writeUnavailable(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		http.Error(w, "short URL not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrExpired):
+		http.Error(w, "short URL has expired", http.StatusGone)
+	case errors.Is(err, service.ErrNotActive):
+		if app.inactivePage == nil {
+			http.Error(w, "short URL not found", http.StatusNotFound)
+			return true
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNotFound)
+
+		if _, err := w.Write(app.inactivePage); err != nil {
+			app.logger.Error("could not write response", zap.Error(err))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// redirectOutcome classifies the result of a redirect for metrics.
+func redirectOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.RedirectFound
+	case errors.Is(err, service.ErrNotFound):
+		return metrics.RedirectNotFound
+	case errors.Is(err, service.ErrExpired):
+		return metrics.RedirectExpired
+	case errors.Is(err, service.ErrNotActive), errors.Is(err, service.ErrPasswordRequired):
+		return metrics.RedirectBlocked
+	}
+	return metrics.RedirectError
+}
+
+func (app *RESTApp) renderUnlockPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := templates.ExecuteTemplate(w, "unlock.html", unlockPage{Error: message}); err != nil {
+		app.logger.Error("could not render unlock page", zap.Error(err))
+	}
+}
+
+// PreviewURL renders a page describing where a short URL leads without redirecting.
+func (app *RESTApp) previewURL() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := RedirectToLongURLRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		u, err := app.service.GetURL(req.Context(), string(shortURL))
+		if err != nil {
+			if errors.Is(err, service.ErrNotFound) {
+				http.Error(w, "short URL not found", http.StatusNotFound)
+				return
+			}
+			app.log(req.Context()).Error("could not get URL", zap.Error(err))
+			http.Error(w, "could not get URL", http.StatusInternalServerError)
+			return
+		}
+
+		page := previewPage{
+			ShortURL:    u.ShortURL,
+			LongURL:     u.LongURL,
+			Protected:   u.PasswordProtected,
+			Inactive:    !u.Active,
+			CreatedAt:   u.CreatedAt,
+			Hits:        u.Hits,
+			ContinueURL: "/" + url.PathEscape(u.ShortURL),
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		if err := templates.ExecuteTemplate(w, "preview.html", page); err != nil {
+			app.log(req.Context()).Error("could not render preview", zap.Error(err))
+			return
+		}
+	}
+}
+
+// GetQRCode returns a QR code image of a short URL.
+func (app *RESTApp) getQRCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		query := req.URL.Query()
+
+		qrReq := GetQRCodeRequest{
+			ShortURL: escapedShortURL,
+			Format:   "png",
+			Size:     defaultQRSize,
+			Level:    "M",
+			Margin:   defaultQRMargin,
+		}
+
+		if format := query.Get("format"); format != "" {
+			qrReq.Format = format
+		}
+
+		if level := query.Get("level"); level != "" {
+			qrReq.Level = level
+		}
+
+		if size := query.Get("size"); size != "" {
+			if qrReq.Size, err = strconv.Atoi(size); err != nil {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if margin := query.Get("margin"); margin != "" {
+			if qrReq.Margin, err = strconv.Atoi(margin); err != nil {
+				http.Error(w, "invalid margin", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := qrReq.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		contentType := "image/png"
+		if qrReq.Format == "svg" {
+			contentType = "image/svg+xml"
+		}
+
+		// The link is checked before the cache so that codes rendered while
+		// it was usable are not served once it is deleted or expires.
+		shortURLInfo, err := app.service.GetURL(req.Context(), qrReq.ShortURL)
+		if err != nil {
+			if errors.Is(err, service.ErrNotFound) {
+				http.Error(w, "short URL not found", http.StatusNotFound)
+				return
+			}
+			app.log(req.Context()).Error("could not get URL", zap.Error(err))
+			http.Error(w, "could not get URL", http.StatusInternalServerError)
+			return
+		}
+
+		if !shortURLInfo.Active {
+			if shortURLInfo.NotAfter != nil && !time.Now().Before(*shortURLInfo.NotAfter) {
+				http.Error(w, "short URL has expired", http.StatusGone)
+				return
+			}
+			http.Error(w, "short URL not found", http.StatusNotFound)
+			return
+		}
+
+		// Validate has accepted the level, so it parses.
+		level, _ := qrcode.ParseLevel(qrReq.Level)
+
+		cacheKey := fmt.Sprintf("%s|%s|%d|%d|%d", qrReq.ShortURL, qrReq.Format, qrReq.Size, level, qrReq.Margin)
+
+		img, ok := app.qrCache.Get(cacheKey)
+		if !ok {
+			opts := qrcode.Options{Size: qrReq.Size, Level: level, Margin: qrReq.Margin}
+
+			if qrReq.Format == "svg" {
+				img, err = qrcode.SVG(qrReq.ShortURL, opts)
+			} else {
+				img, err = qrcode.PNG(qrReq.ShortURL, opts)
+			}
+			if err != nil {
+				app.log(req.Context()).Error("could not render QR code", zap.Error(err))
+				http.Error(w, "could not render QR code", http.StatusInternalServerError)
+				return
+			}
+
+			app.qrCache.Set(cacheKey, img)
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(permanentRedirectMaxAge.Seconds())))
+
+		if _, err := w.Write(img); err != nil {
+			app.log(req.Context()).Error("could not write response", zap.Error(err))
+			return
+		}
+	}
+}
+
+// CreateRule adds a redirect rule to a short URL.
+func (app *RESTApp) createRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := GetStatsRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var reqPayload CreateRuleRequest
+		if err := json.NewDecoder(req.Body).Decode(&reqPayload); err != nil {
+			app.log(req.Context()).Error("could not decode request body", zap.Error(err))
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := reqPayload.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule, err := app.service.AddRule(req.Context(), string(shortURL), service.RuleInput{
+			Kind:      reqPayload.Kind,
+			Condition: reqPayload.Condition,
+			TargetURL: reqPayload.TargetURL,
+			Priority:  reqPayload.Priority,
+		})
+		if err != nil {
+			if errors.Is(err, service.ErrNotFound) {
+				http.Error(w, "short URL not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, service.ErrInvalidRule) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			app.log(req.Context()).Error("could not add rule", zap.Error(err))
+			http.Error(w, "could not add rule", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		if err := json.NewEncoder(w).Encode(newRuleResponse(rule)); err != nil {
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
+			return
+		}
+	}
+}
+
+// ListRules returns the redirect rules of a short URL.
+func (app *RESTApp) listRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := GetStatsRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rules, err := app.service.ListRules(req.Context(), string(shortURL))
+		if err != nil {
+			app.log(req.Context()).Error("could not list rules", zap.Error(err))
+			http.Error(w, "could not list rules", http.StatusInternalServerError)
+			return
+		}
+
+		resp := make([]RuleResponse, 0, len(rules))
+		for _, rule := range rules {
+			resp = append(resp, newRuleResponse(rule))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeleteRule removes a redirect rule from a short URL.
+func (app *RESTApp) deleteRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := GetStatsRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ruleID, err := strconv.ParseInt(chi.URLParam(req, "ruleID"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid rule id", http.StatusBadRequest)
+			return
+		}
+
+		if err := app.service.DeleteRule(req.Context(), string(shortURL), ruleID); err != nil {
+			if errors.Is(err, service.ErrRuleNotFound) {
+				http.Error(w, "rule not found", http.StatusNotFound)
+				return
+			}
+			app.log(req.Context()).Error("could not delete rule", zap.Error(err))
+			http.Error(w, "could not delete rule", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetVariants replaces the weighted destinations a short URL rotates between.
+func (app *RESTApp) setVariants() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := GetStatsRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var reqPayload SetVariantsRequest
+		if err := json.NewDecoder(req.Body).Decode(&reqPayload); err != nil {
+			app.log(req.Context()).Error("could not decode request body", zap.Error(err))
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := reqPayload.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		in := service.VariantSet{Sticky: reqPayload.Sticky}
+		for _, v := range reqPayload.Variants {
+			in.Variants = append(in.Variants, service.Variant{TargetURL: v.TargetURL, Weight: v.Weight})
+		}
+
+		variants, err := app.service.SetVariants(req.Context(), string(shortURL), in)
+		if err != nil {
+			if errors.Is(err, service.ErrNotFound) {
+				http.Error(w, "short URL not found", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, service.ErrInvalidVariant) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			app.log(req.Context()).Error("could not set variants", zap.Error(err))
+			http.Error(w, "could not set variants", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		resp := VariantsResponse{Sticky: variants.Sticky, Variants: newVariantResponses(variants.Variants)}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetVariants returns the weighted destinations of a short URL.
+func (app *RESTApp) getVariants() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
+		if err != nil {
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
+			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
+			return
+		}
+
+		shortURL := GetStatsRequest(escapedShortURL)
+
+		if err := shortURL.Validate(); err != nil {
+			app.log(req.Context()).Error("invalid request", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		variants, err := app.service.GetVariants(req.Context(), string(shortURL))
+		if err != nil {
+			if errors.Is(err, service.ErrNotFound) {
+				http.Error(w, "short URL not found", http.StatusNotFound)
+				return
+			}
+			app.log(req.Context()).Error("could not get variants", zap.Error(err))
+			http.Error(w, "could not get variants", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		resp := VariantsResponse{Sticky: variants.Sticky, Variants: newVariantResponses(variants.Variants)}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
+			http.Error(w, "could not encode response", http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
@@ -128,23 +878,69 @@ func (app *RESTApp) getStats() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		escapedShortURL, err := url.PathUnescape(chi.URLParam(req, "shortURL"))
 		if err != nil {
-			app.logger.Error("could not unescape short URL", zap.Error(err))
+			app.log(req.Context()).Error("could not unescape short URL", zap.Error(err))
 			http.Error(w, "could not unescape short URL", http.StatusInternalServerError)
 			return
 		}
 
 		shortURL := GetStatsRequest(escapedShortURL)
 
-		app.logger.Info("getting stats", zap.String("shortURL", string(shortURL)))
+		app.log(req.Context()).Info("getting stats", zap.String("shortURL", string(shortURL)))
 		if err := shortURL.Validate(); err != nil {
-			app.logger.Error("invalid request body", zap.Error(err))
+			app.log(req.Context()).Error("invalid request body", zap.Error(err))
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		stats, err := app.service.GetStats(req.Context(), string(shortURL))
+		query := req.URL.Query()
+
+		var statsQuery service.StatsQuery
+
+		if from := query.Get("from"); from != "" {
+			if statsQuery.From, err = time.Parse(time.RFC3339, from); err != nil {
+				http.Error(w, "invalid from: must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if to := query.Get("to"); to != "" {
+			if statsQuery.To, err = time.Parse(time.RFC3339, to); err != nil {
+				http.Error(w, "invalid to: must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if limit := query.Get("limit"); limit != "" {
+			if statsQuery.Limit, err = strconv.Atoi(limit); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if offset := query.Get("offset"); offset != "" {
+			if statsQuery.Offset, err = strconv.Atoi(offset); err != nil {
+				http.Error(w, "invalid offset", http.StatusBadRequest)
+				return
+			}
+		}
+
+		statsQuery.Interval = query.Get("interval")
+
+		if tz := query.Get("tz"); tz != "" {
+			// Local names the server's zone, which the database would not know.
+			if statsQuery.Location, err = time.LoadLocation(tz); err != nil || tz == "Local" {
+				http.Error(w, "invalid tz: must be an IANA time zone", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stats, err := app.service.GetStats(req.Context(), string(shortURL), statsQuery)
 		if err != nil {
-			app.logger.Error("could not get stats", zap.Error(err))
+			if errors.Is(err, service.ErrInvalidStatsQuery) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			app.log(req.Context()).Error("could not get stats", zap.Error(err))
 			http.Error(w, "could not get stats", http.StatusInternalServerError)
 			return
 		}
@@ -152,14 +948,143 @@ func (app *RESTApp) getStats() http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 
 		statsResp := GetStatsResponse{
-			ShortURL: string(shortURL),
-			Hits:     stats,
+			ShortURL:            string(shortURL),
+			Hits:                stats.Hits,
+			BotHits:             stats.BotHits,
+			UniqueVisitors:      stats.UniqueVisitors,
+			DailyUniqueVisitors: newDailyVisitorsResponses(stats.DailyUniqueVisitors),
+			Variants:            newVariantResponses(stats.Variants),
+			Referrers:           newSourceCountResponses(stats.Referrers),
+			UTMSources:          newSourceCountResponses(stats.UTMSources),
+			UserAgentFamilies:   newSourceCountResponses(stats.UserAgentFamilies),
+		}
+
+		if stats.Series != nil {
+			statsResp.Series = newBucketResponses(stats.Series)
 		}
 
 		if err := json.NewEncoder(w).Encode(statsResp); err != nil {
-			app.logger.Error("could not encode response", zap.Error(err))
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
 			http.Error(w, "could not encode response", http.StatusInternalServerError)
 			return
 		}
 	}
 }
+
+// linkCookieName returns the name of a cookie holding state about shortURL.
+func linkCookieName(prefix, shortURL string) string {
+	return fmt.Sprintf("%s%x", prefix, sha256.Sum256([]byte(shortURL)))[:len(prefix)+16]
+}
+
+// TopLinks returns the most clicked short URLs in a sliding window.
+func (app *RESTApp) topLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+
+		window := defaultTopLinksWindow
+		if s := query.Get("window"); s != "" {
+			var err error
+			if window, err = time.ParseDuration(s); err != nil {
+				http.Error(w, "invalid window", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var limit int
+		if s := query.Get("limit"); s != "" {
+			var err error
+			if limit, err = strconv.Atoi(s); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		links, err := app.service.TopLinks(req.Context(), window, limit)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidStatsQuery) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			app.log(req.Context()).Error("could not get top links", zap.Error(err))
+			http.Error(w, "could not get top links", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		resp := TopLinksResponse{
+			Window: window.String(),
+			Links:  make([]LinkHitsResponse, 0, len(links)),
+		}
+		for _, link := range links {
+			resp.Links = append(resp.Links, LinkHitsResponse{ShortURL: link.ShortURL, Hits: link.Hits})
+		}
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			app.log(req.Context()).Error("could not encode response", zap.Error(err))
+			return
+		}
+	}
+}
+
+// ExportURLs streams the short URLs created with the API key of the request.
+func (app *RESTApp) exportURLs() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key, ok := apiKeyFromContext(req.Context())
+		if !ok {
+			http.Error(w, "api key required", http.StatusUnauthorized)
+			return
+		}
+
+		format := req.URL.Query().Get("format")
+		if format == "" {
+			format = export.FormatCSV
+		}
+
+		ew, err := export.NewWriter(w, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Exports can take longer than the server write timeout.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			app.log(req.Context()).Warn("could not disable write deadline", zap.Error(err))
+		}
+
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="urls.%s"`, format))
+
+		var written int
+		err = app.service.ExportURLs(req.Context(), key.ID, func(url service.ExportedURL) error {
+			if err := ew.Write(url); err != nil {
+				return err
+			}
+
+			if written++; written%exportFlushEvery == 0 {
+				if err := ew.Flush(); err != nil {
+					return err
+				}
+				return rc.Flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = ew.Flush()
+		}
+		if err != nil {
+			if written == 0 {
+				app.log(req.Context()).Error("could not export short URLs", zap.Error(err))
+				w.Header().Del("Content-Disposition")
+				http.Error(w, "could not export short URLs", http.StatusInternalServerError)
+				return
+			}
+
+			// The status has been sent, so abort the response to tell the
+			// client the export is incomplete.
+			app.log(req.Context()).Error("could not export short URLs", zap.Error(err), zap.Int("written", written))
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
@@ -6,11 +6,16 @@ package app
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/alesr/urltinyizer/internal/botdetect"
+	"github.com/alesr/urltinyizer/internal/health"
 	"github.com/alesr/urltinyizer/internal/repository"
 	"github.com/alesr/urltinyizer/internal/service"
 	"github.com/jmoiron/sqlx"
@@ -113,6 +118,57 @@ func TestRedirectToLongURL(t *testing.T) {
 		require.Equal(t, http.StatusOK, resp.StatusCode)
 	})
 
+	t.Run("permanent redirect", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			"http://localhost:8080/shorten",
+			strings.NewReader(`{"long_url": "https://www.github.com/", "redirect_status": 301}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var response CreateShortURLResponse
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		resp, err = client.Get("http://localhost:8080/" + url.PathEscape(response.ShortURL))
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "https://www.github.com/", resp.Header.Get("Location"))
+		assert.Contains(t, resp.Header.Get("Cache-Control"), "max-age")
+	})
+
+	t.Run("invalid redirect status", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			"http://localhost:8080/shorten",
+			strings.NewReader(`{"long_url": "https://www.github.com/", "redirect_status": 200}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
 	t.Run("failed validation", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/invalid_url", nil)
 		require.NoError(t, err)
@@ -124,6 +180,679 @@ func TestRedirectToLongURL(t *testing.T) {
 	})
 }
 
+func TestPasswordProtectedURL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"http://localhost:8080/shorten",
+		strings.NewReader(`{"long_url": "https://www.example.com/private", "password": "secret"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response CreateShortURLResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	givenShortURL := "http://localhost:8080/" + url.PathEscape(response.ShortURL)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Host != "localhost:8080" {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	t.Run("locked", func(t *testing.T) {
+		resp, err := client.Get(givenShortURL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		resp, err := client.PostForm(givenShortURL, url.Values{"password": {"wrong"}})
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		resp, err := client.PostForm(givenShortURL, url.Values{"password": {"secret"}})
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		assert.Equal(t, "https://www.example.com/private", resp.Header.Get("Location"))
+
+		resp, err = client.Get(givenShortURL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+	})
+}
+
+func TestActivationWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	testCases := []struct {
+		name       string
+		payload    string
+		expectCode int
+	}{
+		{
+			name:       "not active yet",
+			payload:    `{"long_url": "https://www.example.com/launch", "not_before": "2999-01-01T00:00:00Z"}`,
+			expectCode: http.StatusNotFound,
+		},
+		{
+			name:       "expired",
+			payload:    `{"long_url": "https://www.example.com/launch", "not_after": "2000-01-01T00:00:00Z"}`,
+			expectCode: http.StatusGone,
+		},
+		{
+			name:       "active",
+			payload:    `{"long_url": "https://www.example.com/launch", "not_before": "2000-01-01T00:00:00Z", "not_after": "2999-01-01T00:00:00Z"}`,
+			expectCode: http.StatusFound,
+		},
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/shorten", strings.NewReader(tc.payload))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var response CreateShortURLResponse
+			err = json.NewDecoder(resp.Body).Decode(&response)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			resp, err = client.Get("http://localhost:8080/" + url.PathEscape(response.ShortURL))
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestDeviceRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	owner := apiKeyClientHelper(t, ctx, db)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"http://localhost:8080/shorten",
+		strings.NewReader(`{"long_url": "https://www.example.com/app"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := owner.Do(req)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response CreateShortURLResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	givenShortURL := "http://localhost:8080/" + url.PathEscape(response.ShortURL)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var rule RuleResponse
+
+	t.Run("create rule", func(t *testing.T) {
+		resp, err := owner.Post(
+			givenShortURL+"/rules",
+			"application/json",
+			strings.NewReader(`{"kind": "device", "condition": "ios", "target_url": "https://apps.apple.com/app"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rule))
+	})
+
+	t.Run("create rule without api key", func(t *testing.T) {
+		resp, err := http.Post(
+			givenShortURL+"/rules",
+			"application/json",
+			strings.NewReader(`{"kind": "device", "condition": "ios", "target_url": "https://www.evil.com"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("create rule on a link of another api key", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/shorten", strings.NewReader(`{"long_url": "https://www.example.com/owned"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := apiKeyClientHelper(t, ctx, db).Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var owned CreateShortURLResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&owned))
+
+		resp, err = owner.Post(
+			"http://localhost:8080/"+url.PathEscape(owned.ShortURL)+"/rules",
+			"application/json",
+			strings.NewReader(`{"kind": "device", "condition": "ios", "target_url": "https://www.evil.com"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("create rule on a link without owner", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/shorten", strings.NewReader(`{"long_url": "https://www.example.com/anonymous"}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var anonymous CreateShortURLResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&anonymous))
+
+		resp, err = owner.Post(
+			"http://localhost:8080/"+url.PathEscape(anonymous.ShortURL)+"/rules",
+			"application/json",
+			strings.NewReader(`{"kind": "device", "condition": "ios", "target_url": "https://www.evil.com"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		resp, err := owner.Post(
+			givenShortURL+"/rules",
+			"application/json",
+			strings.NewReader(`{"kind": "device", "condition": "toaster", "target_url": "https://apps.apple.com/app"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("invalid schedule", func(t *testing.T) {
+		resp, err := owner.Post(
+			givenShortURL+"/rules",
+			"application/json",
+			strings.NewReader(`{"kind": "schedule", "condition": "weekdays 9-17 Mars/Olympus", "target_url": "https://www.foo.com/chat"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("list rules without api key", func(t *testing.T) {
+		resp, err := http.Get(givenShortURL + "/rules")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("list rules", func(t *testing.T) {
+		resp, err := owner.Get(givenShortURL + "/rules")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var rules []RuleResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&rules))
+
+		require.Len(t, rules, 1)
+		assert.Equal(t, rule.ID, rules[0].ID)
+	})
+
+	t.Run("redirect matching device", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, givenShortURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_3 like Mac OS X) Mobile/15E148")
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, "https://apps.apple.com/app", resp.Header.Get("Location"))
+	})
+
+	t.Run("redirect other device", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, givenShortURL, nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)")
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, "https://www.example.com/app", resp.Header.Get("Location"))
+	})
+
+	t.Run("delete rule", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/rules/%d", givenShortURL, rule.ID), nil)
+		require.NoError(t, err)
+
+		resp, err := owner.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+}
+
+func TestLanguageRules(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	owner := apiKeyClientHelper(t, ctx, db)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"http://localhost:8080/shorten",
+		strings.NewReader(`{"long_url": "https://www.example.com/campaign"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := owner.Do(req)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response CreateShortURLResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	givenShortURL := "http://localhost:8080/" + url.PathEscape(response.ShortURL)
+
+	for _, rule := range []string{
+		`{"kind": "language", "condition": "pt", "target_url": "https://www.example.com/pt"}`,
+		`{"kind": "language", "condition": "de", "target_url": "https://www.example.com/de"}`,
+	} {
+		resp, err := owner.Post(givenShortURL+"/rules", "application/json", strings.NewReader(rule))
+		require.NoError(t, err)
+
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	testCases := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{acceptLanguage: "pt-BR,pt;q=0.9", expected: "https://www.example.com/pt"},
+		{acceptLanguage: "pt;q=0.5,de;q=0.8", expected: "https://www.example.com/de"},
+		{acceptLanguage: "en-US", expected: "https://www.example.com/campaign"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.acceptLanguage, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, givenShortURL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expected, resp.Header.Get("Location"))
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	owner := apiKeyClientHelper(t, ctx, db)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"http://localhost:8080/shorten",
+		strings.NewReader(`{"long_url": "https://www.example.com/landing"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := owner.Do(req)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response CreateShortURLResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	givenShortURL := "http://localhost:8080/" + url.PathEscape(response.ShortURL)
+
+	t.Run("set variants", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPut,
+			givenShortURL+"/variants",
+			strings.NewReader(`{"sticky": true, "variants": [{"target_url": "https://www.example.com/b", "weight": 1}]}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := owner.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var variants VariantsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&variants))
+
+		assert.True(t, variants.Sticky)
+		require.Len(t, variants.Variants, 1)
+	})
+
+	t.Run("set variants without api key", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPut,
+			givenShortURL+"/variants",
+			strings.NewReader(`{"variants": [{"target_url": "https://www.evil.com", "weight": 1}]}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("get variants", func(t *testing.T) {
+		resp, err := owner.Get(givenShortURL + "/variants")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var variants VariantsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&variants))
+
+		require.Len(t, variants.Variants, 1)
+		assert.Equal(t, "https://www.example.com/b", variants.Variants[0].TargetURL)
+	})
+
+	t.Run("get variants without api key", func(t *testing.T) {
+		resp, err := http.Get(givenShortURL + "/variants")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid weight", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPut,
+			givenShortURL+"/variants",
+			strings.NewReader(`{"variants": [{"target_url": "https://www.example.com/b", "weight": 0}]}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := owner.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("redirect to variant", func(t *testing.T) {
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		resp, err := client.Get(givenShortURL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, "https://www.example.com/b", resp.Header.Get("Location"))
+		assert.NotEmpty(t, resp.Cookies())
+	})
+
+	t.Run("variant stats", func(t *testing.T) {
+		resp, err := http.Get(givenShortURL + "/stats")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var stats GetStatsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+
+		require.Len(t, stats.Variants, 1)
+		assert.Equal(t, 1, stats.Variants[0].Hits)
+	})
+}
+
+func TestPreviewURL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	t.Run("preview url", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			"http://localhost:8080/shorten",
+			strings.NewReader(`{"long_url": "https://www.example.com/?q=<script>"}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var response CreateShortURLResponse
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		resp, err = http.Get("http://localhost:8080/" + url.PathEscape(response.ShortURL) + "+")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(body), "https://www.example.com/?q=&lt;script&gt;")
+		assert.NotContains(t, string(body), "<script>")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/" + url.PathEscape("http://foo.com/unknown") + "+")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestGetQRCode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		"http://localhost:8080/shorten",
+		strings.NewReader(`{"long_url": "https://www.example.com/poster"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response CreateShortURLResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	givenShortURL := url.PathEscape(response.ShortURL)
+
+	t.Run("png", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/" + givenShortURL + "/qr?size=128&level=H")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/" + givenShortURL + "/qr?format=svg&margin=0")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "image/svg+xml", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/" + givenShortURL + "/qr?size=10")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/" + url.PathEscape("http://foo.com/unknown") + "/qr")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
 func TestGetStats(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -204,9 +933,224 @@ func TestGetStats(t *testing.T) {
 
 		// Assert that the hits are 5
 		assert.Equal(t, 5, response.Hits)
+
+		// All of them come from the same visitor
+		assert.Equal(t, 1, response.UniqueVisitors)
+		require.Len(t, response.DailyUniqueVisitors, 1)
+		assert.Equal(t, 1, response.DailyUniqueVisitors[0].UniqueVisitors)
+
+		// Unfurl the short url as Slack would
+
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		req, err = http.NewRequest(http.MethodGet, "http://localhost:8080/"+givenShortURL, nil)
+		require.NoError(t, err)
+
+		req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+
+		req, err = http.NewRequest(http.MethodGet, "http://localhost:8080/"+givenShortURL+"/stats", nil)
+		require.NoError(t, err)
+
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+
+		assert.Equal(t, 5, response.Hits)
+		assert.Equal(t, 1, response.BotHits)
+	})
+
+	t.Run("top sources", func(t *testing.T) {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			"http://localhost:8080/shorten",
+			strings.NewReader(`{"long_url": "https://www.example.com/sources"}`),
+		)
+		require.NoError(t, err)
+
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var createShortURLResp CreateShortURLResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&createShortURLResp))
+
+		givenShortURL := url.PathEscape(createShortURLResp.ShortURL)
+
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		visits := []struct {
+			referrer  string
+			utmSource string
+		}{
+			{referrer: "https://news.ycombinator.com/item?id=1", utmSource: "hn"},
+			{referrer: "https://news.ycombinator.com/", utmSource: "hn"},
+			{referrer: "https://www.reddit.com/r/golang"},
+		}
+
+		for _, visit := range visits {
+			target := "http://localhost:8080/" + givenShortURL
+			if visit.utmSource != "" {
+				target += "?utm_source=" + visit.utmSource
+			}
+
+			req, err := http.NewRequest(http.MethodGet, target, nil)
+			require.NoError(t, err)
+
+			req.Header.Set("Referer", visit.referrer)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			resp.Body.Close()
+
+			require.Equal(t, http.StatusFound, resp.StatusCode)
+		}
+
+		resp, err = http.Get("http://localhost:8080/" + givenShortURL + "/stats?limit=1")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var response GetStatsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+
+		assert.Equal(t, []SourceCountResponse{{Value: "news.ycombinator.com", Hits: 2}}, response.Referrers)
+		assert.Equal(t, []SourceCountResponse{{Value: "hn", Hits: 2}}, response.UTMSources)
+		assert.Equal(t, []SourceCountResponse{{Value: "Other", Hits: 3}}, response.UserAgentFamilies)
+
+		from := url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339))
+
+		resp, err = http.Get("http://localhost:8080/" + givenShortURL + "/stats?interval=minute&tz=Europe/Lisbon&from=" + from)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var seriesResponse GetStatsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&seriesResponse))
+
+		require.GreaterOrEqual(t, len(seriesResponse.Series), 60)
+
+		var seriesHits int
+		for _, bucket := range seriesResponse.Series {
+			seriesHits += bucket.Hits
+		}
+		assert.Equal(t, 3, seriesHits)
+
+		resp, err = http.Get("http://localhost:8080/" + givenShortURL + "/stats?from=2023-01-01")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestTopLinks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var shortURLs []string
+	for i, longURL := range []string{"https://www.example.com/top", "https://www.example.com/second"} {
+		resp, err := http.Post(
+			"http://localhost:8080/shorten",
+			"application/json",
+			strings.NewReader(`{"long_url": "`+longURL+`"}`),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var createShortURLResp CreateShortURLResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&createShortURLResp))
+
+		shortURLs = append(shortURLs, createShortURLResp.ShortURL)
+
+		for j := 0; j < 3-i; j++ {
+			resp, err := client.Get("http://localhost:8080/" + url.PathEscape(createShortURLResp.ShortURL))
+			require.NoError(t, err)
+
+			resp.Body.Close()
+		}
+	}
+
+	t.Run("top links", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/api/stats/top?window=1h&limit=1")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var response TopLinksResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+
+		assert.Equal(t, "1h0m0s", response.Window)
+		assert.Equal(t, []LinkHitsResponse{{ShortURL: shortURLs[0], Hits: 3}}, response.Links)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:8080/api/stats/top?window=30d")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	})
 }
 
+// apiKeyClientHelper creates an API key and returns a client sending it.
+func apiKeyClientHelper(t *testing.T, ctx context.Context, db *sqlx.DB) *http.Client {
+	svc := service.NewServiceDefault(zap.NewNop(), "http://foo.com/", repository.NewPostgreSQL(zap.NewNop(), db))
+
+	key, err := svc.CreateAPIKey(ctx, t.Name())
+	require.NoError(t, err)
+
+	return &http.Client{Transport: apiKeyTransport(key.Key)}
+}
+
+// apiKeyTransport sends requests with an API key.
+type apiKeyTransport string
+
+func (k apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+string(k))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
 const (
 	migrationsDir      string = "../migrations"
 	postgresDriverName string = "postgres"
@@ -227,7 +1171,7 @@ func setupHelper(t *testing.T, ctx context.Context) *sqlx.DB {
 	require.NoError(t, goose.Up(db.DB, migrationsDir))
 
 	repo := repository.NewPostgreSQL(zap.NewNop(), db)
-	service := service.NewServiceDefault(zap.NewNop(), "http://foo.com/", repo)
+	service := service.NewServiceDefault(zap.NewNop(), "http://foo.com/", repo, service.WithBotDetector(botdetect.New()))
 
 	testApp := NewREST(zap.NewNop(), chi.NewRouter(), service)
 	testApp.RegisterRoutes()
@@ -238,7 +1182,241 @@ func setupHelper(t *testing.T, ctx context.Context) *sqlx.DB {
 }
 
 func teardownDBHelper(t *testing.T, db *sqlx.DB) {
-	db.MustExec("TRUNCATE TABLE urls RESTART IDENTITY")
+	db.MustExec("TRUNCATE TABLE urls RESTART IDENTITY CASCADE")
 	require.NoError(t, goose.Reset(db.DB, migrationsDir))
 	require.NoError(t, db.Close())
 }
+
+func TestRequestID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	t.Run("propagates request id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/stats/top", nil)
+		require.NoError(t, err)
+
+		req.Header.Set("X-Request-ID", "test-request-id")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, "test-request-id", resp.Header.Get("X-Request-ID"))
+	})
+
+	t.Run("assigns request id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/stats/top", nil)
+		require.NoError(t, err)
+
+		req.Header.Set("X-Request-ID", "invalid request id")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		id := resp.Header.Get("X-Request-ID")
+		assert.NotEmpty(t, id)
+		assert.NotEqual(t, "invalid request id", id)
+	})
+}
+
+func TestExportURLs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	svc := service.NewServiceDefault(zap.NewNop(), "http://foo.com/", repository.NewPostgreSQL(zap.NewNop(), db))
+
+	key, err := svc.CreateAPIKey(ctx, "export")
+	require.NoError(t, err)
+
+	for _, longURL := range []string{"https://www.google.com/", "https://www.bing.com/"} {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			"http://localhost:8080/shorten",
+			strings.NewReader(fmt.Sprintf(`{"long_url": %q}`, longURL)),
+		)
+		require.NoError(t, err)
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key.Key)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// Created without a key, so not part of the export.
+	_, err = svc.CreateShortURL(ctx, service.CreateShortURLInput{LongURL: "https://www.yahoo.com/"})
+	require.NoError(t, err)
+
+	export := func(t *testing.T, query, apiKey string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/api/export"+query, nil)
+		require.NoError(t, err)
+
+		if apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		resp := export(t, "?format=csv", key.Key)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		require.Len(t, lines, 3)
+
+		assert.Equal(t, "short_url,long_url,created_at,hits,last_hit_at", lines[0])
+		assert.Contains(t, lines[1], ",https://www.google.com/,")
+		assert.Contains(t, lines[2], ",https://www.bing.com/,")
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		resp := export(t, "?format=jsonl", key.Key)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		dec := json.NewDecoder(resp.Body)
+
+		var longURLs []string
+		for dec.More() {
+			var line struct {
+				LongURL string `json:"long_url"`
+			}
+			require.NoError(t, dec.Decode(&line))
+			longURLs = append(longURLs, line.LongURL)
+		}
+		assert.Equal(t, []string{"https://www.google.com/", "https://www.bing.com/"}, longURLs)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		resp := export(t, "?format=xml", key.Key)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("missing api key", func(t *testing.T) {
+		resp := export(t, "", "")
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid api key", func(t *testing.T) {
+		resp := export(t, "", "utk_invalid")
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestImportURLs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupHelper(t, ctx)
+	defer teardownDBHelper(t, db)
+
+	svc := service.NewServiceDefault(zap.NewNop(), "http://foo.com/", repository.NewPostgreSQL(zap.NewNop(), db))
+
+	admin := NewAdmin(zap.NewNop(), "localhost:9091", http.NotFoundHandler(), health.New(), svc)
+	go admin.Run(ctx)
+
+	// Taken by a link to another long URL.
+	_, err := svc.ImportURLs(ctx, []service.ImportRecord{{Position: 1, Code: "taken", LongURL: "https://www.bing.com/"}}, 0, false)
+	require.NoError(t, err)
+
+	const export = "keyword,url,timestamp,clicks\n" +
+		"abc,https://www.google.com/,2023-03-01 12:00:00,7\n" +
+		"taken,https://www.yahoo.com/,2023-03-01 12:00:00,3\n" +
+		"bad/code,https://www.google.com/,2023-03-01 12:00:00,1\n"
+
+	importURLs := func(t *testing.T, dryRun bool) ImportResponse {
+		resp, err := http.Post(
+			fmt.Sprintf("http://localhost:9091/import?format=csv&dry_run=%t", dryRun),
+			"text/csv",
+			strings.NewReader(export),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var report ImportResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return report
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		report := importURLs(t, true)
+
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 1, report.Imported)
+
+		var count int
+		require.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM urls WHERE short_url = 'http://foo.com/abc'"))
+		assert.Zero(t, count)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		report := importURLs(t, false)
+
+		assert.False(t, report.DryRun)
+		assert.Equal(t, 1, report.Imported)
+		assert.Equal(t, []ImportIssueResponse{{
+			Position: 3, Code: "taken", LongURL: "https://www.yahoo.com/", Reason: "code already points to https://www.bing.com/",
+		}}, report.Conflicts)
+		require.Len(t, report.Invalid, 1)
+		assert.Equal(t, 4, report.Invalid[0].Position)
+
+		resp, err := http.Get("http://localhost:8080/" + url.PathEscape("http://foo.com/abc") + "/stats")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var stats GetStatsResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+		assert.Equal(t, 7, stats.Hits)
+
+		client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+
+		resp, err = client.Get("http://localhost:8080/" + url.PathEscape("http://foo.com/abc"))
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, "https://www.google.com/", resp.Header.Get("Location"))
+	})
+
+	t.Run("import again", func(t *testing.T) {
+		report := importURLs(t, false)
+
+		assert.Equal(t, 0, report.Imported)
+		assert.Equal(t, 1, report.Unchanged)
+	})
+}
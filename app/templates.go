@@ -0,0 +1,26 @@
+package app
+
+import (
+	"embed"
+	"html/template"
+	"time"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.html"))
+
+type previewPage struct {
+	ShortURL    string
+	LongURL     string
+	Protected   bool
+	Inactive    bool
+	CreatedAt   time.Time
+	Hits        int
+	ContinueURL string
+}
+
+type unlockPage struct {
+	Error string
+}
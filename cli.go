@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+
+	"github.com/alesr/urltinyizer/internal/export"
+	"github.com/alesr/urltinyizer/internal/importer"
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+const commandServe = "serve"
+
+const usage = `Usage: urltinyizer [flags] [command]
+
+Commands:
+  serve                   serve the API (default)
+  migrate up|down|status  apply, roll back one or list the database migrations
+  shorten <url>           create a short URL
+  resolve <code>          print the long URL of a short URL
+  stats <code>            print the stats of a short URL
+  delete <code>           delete a short URL with its rules, variants and stats
+  keys create <name>      create an API key
+  keys revoke <id>        revoke an API key
+  export csv|jsonl [id]   print every short URL, or those created with an API key
+  import [--dry-run] csv|json <file> [id]
+                          import the links of a Bitly or YOURLS export,
+                          owned by an API key when one is given
+
+A code is the part of a short URL after APP_HOST, or the whole short URL.
+
+Flags:
+`
+
+// errUsage is returned when a command is called with the wrong arguments.
+var errUsage = errors.New("invalid usage, run with --help for the list of commands")
+
+// runCommand runs an admin command against the database described by cfg,
+// writing its results to out.
+func runCommand(ctx context.Context, logger *zap.Logger, cfg *config, command string, args []string, out io.Writer) error {
+	db, err := openDB(cfg)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	defer db.Close()
+
+	if command == "migrate" {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		if err := setupMigrations(); err != nil {
+			return err
+		}
+
+		switch args[0] {
+		case "up":
+			return withMigrationLock(ctx, db, func() error {
+				return goose.Up(db.DB, dbMigrationsDir)
+			})
+		case "down":
+			return withMigrationLock(ctx, db, func() error {
+				return goose.Down(db.DB, dbMigrationsDir)
+			})
+		case "status":
+			return goose.Status(db.DB, dbMigrationsDir)
+		}
+		return errUsage
+	}
+
+	svc, closeService, err := newService(logger, cfg, repository.NewPostgreSQL(logger, db))
+	if err != nil {
+		return err
+	}
+
+	defer closeService()
+
+	c := cli{appHost: cfg.AppHost, service: svc, out: out}
+	return c.run(ctx, command, args)
+}
+
+// cli implements the commands that manage links and API keys.
+type cli struct {
+	appHost string
+	service service.Service
+	out     io.Writer
+}
+
+func (c *cli) run(ctx context.Context, command string, args []string) error {
+	switch {
+	case command == "shorten" && len(args) == 1:
+		return c.shorten(ctx, args[0])
+	case command == "resolve" && len(args) == 1:
+		return c.resolve(ctx, c.shortURL(args[0]))
+	case command == "stats" && len(args) == 1:
+		return c.stats(ctx, c.shortURL(args[0]))
+	case command == "delete" && len(args) == 1:
+		return c.delete(ctx, c.shortURL(args[0]))
+	case command == "keys" && len(args) == 2 && args[0] == "create":
+		return c.createKey(ctx, args[1])
+	case command == "keys" && len(args) == 2 && args[0] == "revoke":
+		return c.revokeKey(ctx, args[1])
+	case command == "export" && len(args) == 1:
+		return c.export(ctx, args[0], "")
+	case command == "export" && len(args) == 2:
+		return c.export(ctx, args[0], args[1])
+	case command == "import" && len(args) == 3 && args[0] == "--dry-run":
+		return c.importURLs(ctx, args[1], args[2], "", true)
+	case command == "import" && len(args) == 4 && args[0] == "--dry-run":
+		return c.importURLs(ctx, args[1], args[2], args[3], true)
+	case command == "import" && len(args) == 2:
+		return c.importURLs(ctx, args[0], args[1], "", false)
+	case command == "import" && len(args) == 3:
+		return c.importURLs(ctx, args[0], args[1], args[2], false)
+	}
+	return errUsage
+}
+
+func (c *cli) shorten(ctx context.Context, longURL string) error {
+	shortURL, err := c.service.CreateShortURL(ctx, service.CreateShortURLInput{LongURL: longURL})
+	if err != nil {
+		return fmt.Errorf("could not shorten url: %w", err)
+	}
+
+	fmt.Fprintln(c.out, shortURL)
+	return nil
+}
+
+func (c *cli) resolve(ctx context.Context, shortURL string) error {
+	url, err := c.service.GetURL(ctx, shortURL)
+	if err != nil {
+		return fmt.Errorf("could not resolve url: %w", err)
+	}
+
+	fmt.Fprintln(c.out, url.LongURL)
+	return nil
+}
+
+func (c *cli) stats(ctx context.Context, shortURL string) error {
+	stats, err := c.service.GetStats(ctx, shortURL, service.StatsQuery{})
+	if err != nil {
+		return fmt.Errorf("could not get stats: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "hits\t%d\n", stats.Hits)
+	fmt.Fprintf(w, "bot hits\t%d\n", stats.BotHits)
+	fmt.Fprintf(w, "unique visitors\t%d\n", stats.UniqueVisitors)
+
+	for _, v := range stats.Variants {
+		fmt.Fprintf(w, "variant %s\t%d\n", v.TargetURL, v.Hits)
+	}
+
+	for _, section := range []struct {
+		name    string
+		sources []service.SourceCount
+	}{
+		{"referrer", stats.Referrers},
+		{"utm source", stats.UTMSources},
+		{"browser", stats.UserAgentFamilies},
+	} {
+		for _, source := range section.sources {
+			fmt.Fprintf(w, "%s %s\t%d\n", section.name, source.Value, source.Hits)
+		}
+	}
+	return w.Flush()
+}
+
+func (c *cli) delete(ctx context.Context, shortURL string) error {
+	if err := c.service.DeleteURL(ctx, shortURL); err != nil {
+		return fmt.Errorf("could not delete url: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "deleted %s\n", shortURL)
+	return nil
+}
+
+func (c *cli) createKey(ctx context.Context, name string) error {
+	key, err := c.service.CreateAPIKey(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not create api key: %w", err)
+	}
+
+	// The key is not stored, so this is the only time it can be shown.
+	fmt.Fprintf(c.out, "id\t%d\nname\t%s\nkey\t%s\n", key.ID, key.Name, key.Key)
+	return nil
+}
+
+func (c *cli) revokeKey(ctx context.Context, arg string) error {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid api key id %q: %w", arg, errUsage)
+	}
+
+	if err := c.service.RevokeAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("could not revoke api key: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "revoked api key %d\n", id)
+	return nil
+}
+
+func (c *cli) export(ctx context.Context, format, keyID string) error {
+	ownerKeyID, err := parseOwnerKeyID(keyID)
+	if err != nil {
+		return err
+	}
+
+	w, err := export.NewWriter(c.out, format)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, errUsage)
+	}
+
+	if err := c.service.ExportURLs(ctx, ownerKeyID, w.Write); err != nil {
+		return fmt.Errorf("could not export urls: %w", err)
+	}
+	return w.Flush()
+}
+
+// parseOwnerKeyID parses the optional API key id argument of a command,
+// which is zero when it is not given.
+func parseOwnerKeyID(keyID string) (int64, error) {
+	if keyID == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(keyID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid api key id %q: %w", keyID, errUsage)
+	}
+	return id, nil
+}
+
+func (c *cli) importURLs(ctx context.Context, format, path, keyID string, dryRun bool) error {
+	ownerKeyID, err := parseOwnerKeyID(keyID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open export: %w", err)
+	}
+
+	defer f.Close()
+
+	records, err := importer.Read(f, format)
+	if err != nil {
+		if errors.Is(err, importer.ErrUnknownFormat) {
+			return fmt.Errorf("%v: %w", err, errUsage)
+		}
+		return fmt.Errorf("could not read export: %w", err)
+	}
+
+	report, err := c.service.ImportURLs(ctx, records, ownerKeyID, dryRun)
+	if err != nil {
+		return fmt.Errorf("could not import urls: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+
+	imported := "imported"
+	if report.DryRun {
+		imported = "would import"
+	}
+
+	fmt.Fprintf(w, "%s\t%d\n", imported, report.Imported)
+	fmt.Fprintf(w, "unchanged\t%d\n", report.Unchanged)
+	fmt.Fprintf(w, "conflicts\t%d\n", len(report.Conflicts))
+	fmt.Fprintf(w, "invalid\t%d\n", len(report.Invalid))
+
+	for _, section := range []struct {
+		name   string
+		issues []service.ImportIssue
+	}{
+		{"conflict", report.Conflicts},
+		{"invalid", report.Invalid},
+	} {
+		for _, issue := range section.issues {
+			fmt.Fprintf(w, "%s at %d\t%s\t%s\t%s\n", section.name, issue.Position, issue.Code, issue.LongURL, issue.Reason)
+		}
+	}
+	return w.Flush()
+}
+
+// shortURL returns the short URL of code, which may already be a short URL.
+func (c *cli) shortURL(code string) string {
+	if strings.HasPrefix(code, c.appHost) {
+		return code
+	}
+	return c.appHost + code
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.uber.org/zap"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+func TestCLI(t *testing.T) {
+	t.Parallel()
+
+	exportPath := filepath.Join(t.TempDir(), "yourls.csv")
+	require.NoError(t, os.WriteFile(exportPath, []byte("keyword,url,clicks\nabc,https://www.foo.com,7\ndef,https://www.bar.com,1\n"), 0o600))
+
+	svc := &service.Mock{
+		CreateShortURLFunc: func(ctx context.Context, in service.CreateShortURLInput) (string, error) {
+			return "http://localhost:8080/7633a1", nil
+		},
+		GetURLFunc: func(ctx context.Context, shortURL string) (service.URL, error) {
+			if shortURL != "http://localhost:8080/7633a1" {
+				return service.URL{}, service.ErrNotFound
+			}
+			return service.URL{ShortURL: shortURL, LongURL: "https://www.foo.com"}, nil
+		},
+		GetStatsFunc: func(ctx context.Context, shortURL string, query service.StatsQuery) (service.Stats, error) {
+			return service.Stats{
+				Hits:           12,
+				BotHits:        3,
+				UniqueVisitors: 10,
+				Referrers:      []service.SourceCount{{Value: "news.ycombinator.com", Hits: 7}},
+			}, nil
+		},
+		DeleteURLFunc: func(ctx context.Context, shortURL string) error {
+			return nil
+		},
+		CreateAPIKeyFunc: func(ctx context.Context, name string) (service.APIKey, error) {
+			return service.APIKey{ID: 3, Name: name, Key: "utk_secret"}, nil
+		},
+		RevokeAPIKeyFunc: func(ctx context.Context, id int64) error {
+			return nil
+		},
+		ExportURLsFunc: func(ctx context.Context, ownerKeyID int64, fn func(service.ExportedURL) error) error {
+			if ownerKeyID != 0 && ownerKeyID != 3 {
+				return nil
+			}
+			return fn(service.ExportedURL{
+				ShortURL:  "http://localhost:8080/7633a1",
+				LongURL:   "https://www.foo.com",
+				CreatedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+				Hits:      12,
+			})
+		},
+		ImportURLsFunc: func(ctx context.Context, records []service.ImportRecord, ownerKeyID int64, dryRun bool) (service.ImportReport, error) {
+			if ownerKeyID != 0 && ownerKeyID != 3 {
+				return service.ImportReport{}, service.ErrAPIKeyNotFound
+			}
+			return service.ImportReport{
+				DryRun:   dryRun,
+				Imported: len(records) - 1,
+				Conflicts: []service.ImportIssue{{
+					Position: records[1].Position,
+					Code:     records[1].Code,
+					LongURL:  records[1].LongURL,
+					Reason:   "code already points to https://www.baz.com",
+				}},
+			}, nil
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		command  string
+		args     []string
+		expected string
+		err      error
+	}{
+		{
+			name:     "shorten",
+			command:  "shorten",
+			args:     []string{"https://www.foo.com"},
+			expected: "http://localhost:8080/7633a1\n",
+		},
+		{
+			name:     "resolve code",
+			command:  "resolve",
+			args:     []string{"7633a1"},
+			expected: "https://www.foo.com\n",
+		},
+		{
+			name:     "resolve short url",
+			command:  "resolve",
+			args:     []string{"http://localhost:8080/7633a1"},
+			expected: "https://www.foo.com\n",
+		},
+		{
+			name:    "resolve unknown code",
+			command: "resolve",
+			args:    []string{"unknown"},
+			err:     service.ErrNotFound,
+		},
+		{
+			name:    "stats",
+			command: "stats",
+			args:    []string{"7633a1"},
+			expected: "hits                           12\n" +
+				"bot hits                       3\n" +
+				"unique visitors                10\n" +
+				"referrer news.ycombinator.com  7\n",
+		},
+		{
+			name:     "delete",
+			command:  "delete",
+			args:     []string{"7633a1"},
+			expected: "deleted http://localhost:8080/7633a1\n",
+		},
+		{
+			name:     "create key",
+			command:  "keys",
+			args:     []string{"create", "ci"},
+			expected: "id\t3\nname\tci\nkey\tutk_secret\n",
+		},
+		{
+			name:     "revoke key",
+			command:  "keys",
+			args:     []string{"revoke", "3"},
+			expected: "revoked api key 3\n",
+		},
+		{
+			name:    "revoke key with invalid id",
+			command: "keys",
+			args:    []string{"revoke", "ci"},
+			err:     errUsage,
+		},
+		{
+			name:    "export csv",
+			command: "export",
+			args:    []string{"csv"},
+			expected: "short_url,long_url,created_at,hits,last_hit_at\n" +
+				"http://localhost:8080/7633a1,https://www.foo.com,2023-03-01T12:00:00Z,12,\n",
+		},
+		{
+			name:     "export jsonl of key",
+			command:  "export",
+			args:     []string{"jsonl", "3"},
+			expected: `{"short_url":"http://localhost:8080/7633a1","long_url":"https://www.foo.com","created_at":"2023-03-01T12:00:00Z","hits":12,"last_hit_at":null}` + "\n",
+		},
+		{
+			name:     "export of key without urls",
+			command:  "export",
+			args:     []string{"jsonl", "4"},
+			expected: "",
+		},
+		{
+			name:    "export unknown format",
+			command: "export",
+			args:    []string{"xml"},
+			err:     errUsage,
+		},
+		{
+			name:    "import",
+			command: "import",
+			args:    []string{"csv", exportPath},
+			expected: "imported       1\n" +
+				"unchanged      0\n" +
+				"conflicts      1\n" +
+				"invalid        0\n" +
+				"conflict at 3  def  https://www.bar.com  code already points to https://www.baz.com\n",
+		},
+		{
+			name:    "import dry run",
+			command: "import",
+			args:    []string{"--dry-run", "csv", exportPath},
+			expected: "would import   1\n" +
+				"unchanged      0\n" +
+				"conflicts      1\n" +
+				"invalid        0\n" +
+				"conflict at 3  def  https://www.bar.com  code already points to https://www.baz.com\n",
+		},
+		{
+			name:    "import owned by key",
+			command: "import",
+			args:    []string{"--dry-run", "csv", exportPath, "3"},
+			expected: "would import   1\n" +
+				"unchanged      0\n" +
+				"conflicts      1\n" +
+				"invalid        0\n" +
+				"conflict at 3  def  https://www.bar.com  code already points to https://www.baz.com\n",
+		},
+		{
+			name:    "import owned by unknown key",
+			command: "import",
+			args:    []string{"csv", exportPath, "4"},
+			err:     service.ErrAPIKeyNotFound,
+		},
+		{
+			name:    "import invalid key id",
+			command: "import",
+			args:    []string{"csv", exportPath, "three"},
+			err:     errUsage,
+		},
+		{
+			name:    "import unknown format",
+			command: "import",
+			args:    []string{"xml", exportPath},
+			err:     errUsage,
+		},
+		{
+			name:    "missing argument",
+			command: "shorten",
+			err:     errUsage,
+		},
+		{
+			name:    "unknown command",
+			command: "unknown",
+			err:     errUsage,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			c := cli{appHost: "http://localhost:8080/", service: svc, out: &out}
+
+			err := c.run(context.Background(), tc.command, tc.args)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestCLIShortenInvalidURL(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewServiceDefault(zap.NewNop(), "http://localhost:8080/", &repository.Mock{})
+
+	var out bytes.Buffer
+	c := cli{appHost: "http://localhost:8080/", service: svc, out: &out}
+
+	err := c.run(context.Background(), "shorten", []string{"foo"})
+	require.ErrorIs(t, err, service.ErrInvalidURL)
+
+	assert.Empty(t, out.String())
+}
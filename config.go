@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	envars "github.com/netflix/go-env"
+	"gopkg.in/yaml.v3"
+
+	"github.com/alesr/urltinyizer/internal/service"
+	"github.com/alesr/urltinyizer/internal/tracing"
+)
+
+// configFileEnv is the environment variable naming the config file when
+// the --config flag is not given.
+const configFileEnv = "CONFIG_FILE"
+
+// redacted replaces secrets in the output of --print-config.
+const redacted = "REDACTED"
+
+type config struct {
+	AppHost string `env:"APP_HOST,default=http://localhost:8080/" yaml:"app_host"`
+	DBUser  string `env:"POSTGRES_USER,default=user" yaml:"postgres_user"`
+	DBPass  string `env:"POSTGRES_PASSWORD,default=password" yaml:"postgres_password" secret:"true"`
+	DBName  string `env:"POSTGRES_DB,default=urltinyizer" yaml:"postgres_db"`
+	DBHost  string `env:"POSTGRES_HOST,default=db" yaml:"postgres_host"`
+	DBPort  string `env:"POSTGRES_PORT,default=5432" yaml:"postgres_port"`
+
+	AutoMigrate bool `env:"AUTO_MIGRATE,default=true" yaml:"auto_migrate"`
+
+	DefaultRedirectStatus int `env:"DEFAULT_REDIRECT_STATUS,default=302" yaml:"default_redirect_status"`
+
+	UnlockSecret        string        `env:"UNLOCK_SECRET" yaml:"unlock_secret" secret:"true"`
+	UnlockTTL           time.Duration `env:"UNLOCK_TTL,default=1h" yaml:"unlock_ttl"`
+	UnlockMaxAttempts   int           `env:"UNLOCK_MAX_ATTEMPTS,default=5" yaml:"unlock_max_attempts"`
+	UnlockAttemptWindow time.Duration `env:"UNLOCK_ATTEMPT_WINDOW,default=15m" yaml:"unlock_attempt_window"`
+
+	InactiveLinkPage string `env:"INACTIVE_LINK_PAGE" yaml:"inactive_link_page"`
+
+	GeoIPDBPath    string `env:"GEOIP_DB_PATH" yaml:"geoip_db_path"`
+	TrustedProxies string `env:"TRUSTED_PROXIES" yaml:"trusted_proxies"`
+
+	VisitorSalt string `env:"VISITOR_SALT" yaml:"visitor_salt" secret:"true"`
+
+	BotIPRanges string `env:"BOT_IP_RANGES" yaml:"bot_ip_ranges"`
+
+	AdminAddr string `env:"ADMIN_ADDR,default=:9090" yaml:"admin_addr"`
+
+	HTTPAddr              string        `env:"HTTP_ADDR,default=:8080" yaml:"http_addr"`
+	HTTPReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT,default=5s" yaml:"http_read_timeout"`
+	HTTPReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT,default=5s" yaml:"http_read_header_timeout"`
+	HTTPWriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT,default=10s" yaml:"http_write_timeout"`
+	HTTPIdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT,default=2m" yaml:"http_idle_timeout"`
+	HTTPH2C               bool          `env:"HTTP_H2C,default=false" yaml:"http_h2c"`
+	ShutdownGracePeriod   time.Duration `env:"SHUTDOWN_GRACE_PERIOD,default=15s" yaml:"shutdown_grace_period"`
+
+	TLSCertFile string `env:"TLS_CERT_FILE" yaml:"tls_cert_file"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE" yaml:"tls_key_file"`
+
+	TracesExporter    string  `env:"TRACES_EXPORTER,default=none" yaml:"traces_exporter"`
+	TracesFile        string  `env:"TRACES_FILE,default=traces.jsonl" yaml:"traces_file"`
+	TracesSampleRatio float64 `env:"TRACES_SAMPLE_RATIO,default=1" yaml:"traces_sample_ratio"`
+}
+
+// newConfig loads the config from the command line and environment and
+// returns the arguments left after the flags.
+func newConfig() (*config, []string) {
+	fs := flag.NewFlagSet("urltinyizer", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	printConfig := fs.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+
+	cfg, err := loadConfig(fs, os.Args[1:], os.Environ())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *printConfig {
+		if err := cfg.print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+	return cfg, fs.Args()
+}
+
+// loadConfig builds the config from its defaults, overridden by the config
+// file, then by environment variables and finally by the flags in args,
+// which are parsed with fs.
+func loadConfig(fs *flag.FlagSet, args, environ []string) (*config, error) {
+	configFile := fs.String("config", "", "path to a YAML config file (env "+configFileEnv+")")
+
+	// Every config field gets a flag named after its environment variable,
+	// e.g. APP_HOST is set with --app-host.
+	flags := envars.EnvSet{}
+	for _, key := range configKeys() {
+		key := key
+		name := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+
+		fs.Func(name, "overrides "+key, func(value string) error {
+			flags[key] = value
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	env, err := envars.EnvironToEnvSet(environ)
+	if err != nil {
+		return nil, fmt.Errorf("could not read environment: %w", err)
+	}
+
+	var cfg config
+	if err := envars.Unmarshal(envars.EnvSet{}, &cfg); err != nil {
+		return nil, fmt.Errorf("could not set config defaults: %w", err)
+	}
+
+	if *configFile == "" {
+		*configFile = env[configFileEnv]
+	}
+
+	if *configFile != "" {
+		if err := cfg.readFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Marshalling the config gives every key a value, so the defaults
+	// go-env falls back to cannot override the config file.
+	values, err := envars.Marshal(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal config: %w", err)
+	}
+
+	for _, overrides := range []envars.EnvSet{env, flags} {
+		for key, value := range overrides {
+			if _, ok := values[key]; ok {
+				values[key] = value
+			}
+		}
+	}
+
+	if err := envars.Unmarshal(values, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse config: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// readFile decodes the YAML config file at path into cfg. Keys missing
+// from the file keep their current value and unknown keys are rejected.
+func (cfg *config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate returns an error listing every invalid setting.
+func (cfg *config) validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(cfg.AppHost); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		addf("APP_HOST %q must be an absolute http or https URL", cfg.AppHost)
+	} else if !strings.HasSuffix(cfg.AppHost, "/") {
+		addf("APP_HOST %q must end with a slash", cfg.AppHost)
+	}
+
+	if !validPort(cfg.DBPort) {
+		addf("POSTGRES_PORT %q must be a port between 1 and 65535", cfg.DBPort)
+	}
+
+	if !service.ValidRedirectStatus(cfg.DefaultRedirectStatus) {
+		addf("DEFAULT_REDIRECT_STATUS %d must be 301, 302, 307 or 308", cfg.DefaultRedirectStatus)
+	}
+
+	if cfg.UnlockTTL <= 0 {
+		addf("UNLOCK_TTL %s must be positive", cfg.UnlockTTL)
+	}
+
+	if cfg.UnlockMaxAttempts <= 0 {
+		addf("UNLOCK_MAX_ATTEMPTS %d must be positive", cfg.UnlockMaxAttempts)
+	}
+
+	if cfg.UnlockAttemptWindow <= 0 {
+		addf("UNLOCK_ATTEMPT_WINDOW %s must be positive", cfg.UnlockAttemptWindow)
+	}
+
+	for key, addr := range map[string]string{"HTTP_ADDR": cfg.HTTPAddr, "ADMIN_ADDR": cfg.AdminAddr} {
+		if _, port, err := net.SplitHostPort(addr); err != nil || !validPort(port) {
+			addf("%s %q must be a host:port address with a port between 1 and 65535", key, addr)
+		}
+	}
+
+	for key, d := range map[string]time.Duration{
+		"HTTP_READ_TIMEOUT":        cfg.HTTPReadTimeout,
+		"HTTP_READ_HEADER_TIMEOUT": cfg.HTTPReadHeaderTimeout,
+		"HTTP_WRITE_TIMEOUT":       cfg.HTTPWriteTimeout,
+		"HTTP_IDLE_TIMEOUT":        cfg.HTTPIdleTimeout,
+	} {
+		if d < 0 {
+			addf("%s %s must not be negative", key, d)
+		}
+	}
+
+	if cfg.ShutdownGracePeriod <= 0 {
+		addf("SHUTDOWN_GRACE_PERIOD %s must be positive", cfg.ShutdownGracePeriod)
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		addf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	switch cfg.TracesExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterFile:
+	default:
+		addf("TRACES_EXPORTER %q must be none, otlp, stdout or file", cfg.TracesExporter)
+	}
+
+	if cfg.TracesSampleRatio < 0 || cfg.TracesSampleRatio > 1 {
+		addf("TRACES_SAMPLE_RATIO %v must be between 0 and 1", cfg.TracesSampleRatio)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	// Map iteration order is random, so sort for stable output.
+	sort.Strings(problems)
+	return fmt.Errorf("invalid config:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// print writes cfg as YAML with the secret fields redacted.
+func (cfg *config) print(w io.Writer) error {
+	out := *cfg
+
+	v := reflect.ValueOf(&out).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("secret") == "true" && v.Field(i).String() != "" {
+			v.Field(i).SetString(redacted)
+		}
+	}
+
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("could not encode config: %w", err)
+	}
+	return nil
+}
+
+// configKeys returns the environment variable of each config field.
+func configKeys() []string {
+	t := reflect.TypeOf(config{})
+
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("env")
+		keys = append(keys, strings.SplitN(tag, ",", 2)[0])
+	}
+	return keys
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port <= 65535
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+app_host: https://file.example.com/
+postgres_host: file-db
+http_write_timeout: 30s
+unlock_max_attempts: 3
+`), 0o600))
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := loadConfig(newFlagSetHelper(), nil, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, "http://localhost:8080/", cfg.AppHost)
+		assert.Equal(t, ":8080", cfg.HTTPAddr)
+		assert.Equal(t, 10*time.Second, cfg.HTTPWriteTimeout)
+	})
+
+	t.Run("file < env < flags", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := loadConfig(
+			newFlagSetHelper(),
+			[]string{"--config", path, "--postgres-host", "flag-db"},
+			[]string{"POSTGRES_HOST=env-db", "UNLOCK_MAX_ATTEMPTS=4"},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://file.example.com/", cfg.AppHost)
+		assert.Equal(t, 30*time.Second, cfg.HTTPWriteTimeout)
+		assert.Equal(t, 4, cfg.UnlockMaxAttempts)
+		assert.Equal(t, "flag-db", cfg.DBHost)
+		assert.Equal(t, "urltinyizer", cfg.DBName)
+	})
+
+	t.Run("config file from env", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := loadConfig(newFlagSetHelper(), nil, []string{"CONFIG_FILE=" + path})
+		require.NoError(t, err)
+
+		assert.Equal(t, "file-db", cfg.DBHost)
+	})
+
+	t.Run("unknown key in file", func(t *testing.T) {
+		t.Parallel()
+
+		badPath := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(badPath, []byte("app_hots: https://example.com/\n"), 0o600))
+
+		_, err := loadConfig(newFlagSetHelper(), []string{"--config", badPath}, nil)
+		require.ErrorContains(t, err, "app_hots")
+	})
+
+	t.Run("invalid config lists every problem", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := loadConfig(
+			newFlagSetHelper(),
+			[]string{"--app-host", "https://example.com"},
+			[]string{"POSTGRES_PORT=70000", "HTTP_ADDR=8080"},
+		)
+		require.Error(t, err)
+
+		assert.Equal(t, `invalid config:
+  - APP_HOST "https://example.com" must end with a slash
+  - HTTP_ADDR "8080" must be a host:port address with a port between 1 and 65535
+  - POSTGRES_PORT "70000" must be a port between 1 and 65535`, err.Error())
+	})
+
+	t.Run("print config", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := loadConfig(newFlagSetHelper(), []string{"--unlock-secret", "s3cr3t"}, nil)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, cfg.print(&buf))
+
+		assert.Contains(t, buf.String(), "unlock_secret: REDACTED\n")
+		assert.Contains(t, buf.String(), "postgres_password: REDACTED\n")
+		assert.Contains(t, buf.String(), "visitor_salt: \"\"\n")
+		assert.Contains(t, buf.String(), "http_write_timeout: 10s\n")
+		assert.NotContains(t, buf.String(), "s3cr3t")
+	})
+}
+
+func TestLoadConfigArgs(t *testing.T) {
+	t.Parallel()
+
+	fs := newFlagSetHelper()
+
+	cfg, err := loadConfig(fs, []string{"--postgres-host", "flag-db", "migrate", "up"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "flag-db", cfg.DBHost)
+	assert.Equal(t, []string{"migrate", "up"}, fs.Args())
+}
+
+func newFlagSetHelper() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
@@ -0,0 +1,108 @@
+// Package botdetect classifies requests made by crawlers, link unfurlers and
+// scanners rather than by people.
+package botdetect
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/alesr/urltinyizer/internal/netutil"
+)
+
+//go:embed patterns.txt
+var patternsFile string
+
+// patterns are the lowercase User-Agent substrings identifying bots.
+var patterns = parsePatterns(patternsFile)
+
+// Detector classifies requests as bots by their User-Agent and IP address.
+// It is safe for concurrent use.
+type Detector struct {
+	networks []*net.IPNet
+}
+
+// New returns a detector matching the built-in User-Agent patterns and,
+// optionally, requests coming from any of networks.
+func New(networks ...*net.IPNet) *Detector {
+	return &Detector{networks: networks}
+}
+
+// IsBot reports whether a request with the given User-Agent and client IP
+// comes from a bot. Requests without a User-Agent are considered bots.
+func (d *Detector) IsBot(userAgent string, ip net.IP) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if strings.Contains(ua, pattern) {
+			return true
+		}
+	}
+
+	if ip != nil {
+		for _, network := range d.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadNetworks reads IP addresses and CIDR networks from a file, one per
+// line. Blank lines and lines starting with # are ignored.
+func ReadNetworks(path string) ([]*net.IPNet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open ip ranges: %w", err)
+	}
+	defer f.Close()
+
+	networks, err := parseNetworks(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ip ranges from %s: %w", path, err)
+	}
+	return networks, nil
+}
+
+func parseNetworks(r io.Reader) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		network, err := netutil.ParseNetwork(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		networks = append(networks, network)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
+func parsePatterns(s string) []string {
+	var result []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package botdetect
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBot(t *testing.T) {
+	t.Parallel()
+
+	networks, err := parseNetworks(strings.NewReader("# scanners\n198.51.100.0/24\n\n2001:db8::1\n"))
+	require.NoError(t, err)
+
+	detector := New(networks...)
+
+	testCases := []struct {
+		name      string
+		userAgent string
+		ip        string
+		expected  bool
+	}{
+		{
+			name:      "browser",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			ip:        "192.0.2.1",
+			expected:  false,
+		},
+		{
+			name:      "slack unfurler",
+			userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			ip:        "192.0.2.1",
+			expected:  true,
+		},
+		{
+			name:      "twitter unfurler",
+			userAgent: "Twitterbot/1.0",
+			expected:  true,
+		},
+		{
+			name:      "facebook unfurler",
+			userAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			expected:  true,
+		},
+		{
+			name:      "empty user agent",
+			userAgent: "",
+			expected:  true,
+		},
+		{
+			name:      "browser from scanner network",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			ip:        "198.51.100.7",
+			expected:  true,
+		},
+		{
+			name:      "browser from scanner address",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			ip:        "2001:db8::1",
+			expected:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, detector.IsBot(tc.userAgent, net.ParseIP(tc.ip)))
+		})
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseNetworks(strings.NewReader("198.51.100.0/24\nnot-an-ip\n"))
+	require.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,52 @@
+// Package certreload serves a TLS certificate that can be reloaded from
+// disk without restarting the server.
+package certreload
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// Reloader holds a certificate and key pair loaded from files. It is safe
+// for concurrent use.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// New loads the certificate and key pair from certFile and keyFile.
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate and key pair again. On error the previous
+// certificate keeps being served.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	return nil
+}
+
+// GetCertificate returns the current certificate. It is meant to be used
+// as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
@@ -0,0 +1,85 @@
+package certreload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeCertHelper(t, certFile, keyFile, "first.example.com")
+
+	r, err := New(certFile, keyFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "first.example.com", commonNameHelper(t, r))
+
+	writeCertHelper(t, certFile, keyFile, "second.example.com")
+	require.NoError(t, r.Reload())
+
+	assert.Equal(t, "second.example.com", commonNameHelper(t, r))
+
+	// A broken pair is rejected and the previous certificate is kept.
+	require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o600))
+	require.Error(t, r.Reload())
+
+	assert.Equal(t, "second.example.com", commonNameHelper(t, r))
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(filepath.Join(t.TempDir(), "missing.pem"), filepath.Join(t.TempDir(), "missing.key"))
+	require.Error(t, err)
+}
+
+func commonNameHelper(t *testing.T, r *Reloader) string {
+	t.Helper()
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+func writeCertHelper(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
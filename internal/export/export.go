@@ -0,0 +1,130 @@
+// Package export writes short URLs as CSV or JSON Lines.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+// Formats short URLs can be exported in.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// ErrUnknownFormat is returned for a format other than the supported ones.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// header is the first row of CSV exports.
+var header = []string{"short_url", "long_url", "created_at", "hits", "last_hit_at"}
+
+// Writer writes exported short URLs one at a time.
+type Writer interface {
+	Write(url service.ExportedURL) error
+	// Flush writes any buffered data to the underlying writer.
+	Flush() error
+}
+
+// ContentType returns the MIME type of format.
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter returns a writer encoding short URLs to w in format.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("%q: %w", format, ErrUnknownFormat)
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvWriter) Write(url service.ExportedURL) error {
+	if !c.wroteHeader {
+		if err := c.w.Write(header); err != nil {
+			return fmt.Errorf("could not write csv header: %w", err)
+		}
+		c.wroteHeader = true
+	}
+
+	lastHitAt := ""
+	if url.LastHitAt != nil {
+		lastHitAt = url.LastHitAt.UTC().Format(time.RFC3339)
+	}
+
+	if err := c.w.Write([]string{
+		url.ShortURL,
+		url.LongURL,
+		url.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(url.Hits),
+		lastHitAt,
+	}); err != nil {
+		return fmt.Errorf("could not write csv row: %w", err)
+	}
+	return nil
+}
+
+func (c *csvWriter) Flush() error {
+	// An empty export still gets its header.
+	if !c.wroteHeader {
+		if err := c.w.Write(header); err != nil {
+			return fmt.Errorf("could not write csv header: %w", err)
+		}
+		c.wroteHeader = true
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+type jsonlRecord struct {
+	ShortURL  string     `json:"short_url"`
+	LongURL   string     `json:"long_url"`
+	CreatedAt time.Time  `json:"created_at"`
+	Hits      int        `json:"hits"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+}
+
+func (j *jsonlWriter) Write(url service.ExportedURL) error {
+	record := jsonlRecord{
+		ShortURL:  url.ShortURL,
+		LongURL:   url.LongURL,
+		CreatedAt: url.CreatedAt.UTC(),
+		Hits:      url.Hits,
+	}
+
+	if url.LastHitAt != nil {
+		lastHitAt := url.LastHitAt.UTC()
+		record.LastHitAt = &lastHitAt
+	}
+
+	if err := j.enc.Encode(record); err != nil {
+		return fmt.Errorf("could not write json line: %w", err)
+	}
+	return nil
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	lastHitAt := createdAt.Add(90 * time.Minute)
+
+	urls := []service.ExportedURL{
+		{ShortURL: "http://bar/a", LongURL: "https://www.foo.com/a?x=1,2", CreatedAt: createdAt, Hits: 2, LastHitAt: &lastHitAt},
+		{ShortURL: "http://bar/b", LongURL: "https://www.foo.com/b", CreatedAt: createdAt},
+	}
+
+	testCases := []struct {
+		name     string
+		format   string
+		urls     []service.ExportedURL
+		expected string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			urls:   urls,
+			expected: "short_url,long_url,created_at,hits,last_hit_at\n" +
+				"http://bar/a,\"https://www.foo.com/a?x=1,2\",2023-03-01T12:00:00Z,2,2023-03-01T13:30:00Z\n" +
+				"http://bar/b,https://www.foo.com/b,2023-03-01T12:00:00Z,0,\n",
+		},
+		{
+			name:     "empty csv",
+			format:   FormatCSV,
+			expected: "short_url,long_url,created_at,hits,last_hit_at\n",
+		},
+		{
+			name:   "jsonl",
+			format: FormatJSONL,
+			urls:   urls,
+			expected: `{"short_url":"http://bar/a","long_url":"https://www.foo.com/a?x=1,2","created_at":"2023-03-01T12:00:00Z","hits":2,"last_hit_at":"2023-03-01T13:30:00Z"}` + "\n" +
+				`{"short_url":"http://bar/b","long_url":"https://www.foo.com/b","created_at":"2023-03-01T12:00:00Z","hits":0,"last_hit_at":null}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			w, err := NewWriter(&buf, tc.format)
+			require.NoError(t, err)
+
+			for _, url := range tc.urls {
+				require.NoError(t, w.Write(url))
+			}
+			require.NoError(t, w.Flush())
+
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewWriter(&bytes.Buffer{}, "xml")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,52 @@
+// Package geoip resolves the country of IP addresses from a local
+// MaxMind-format (.mmdb) database such as GeoLite2 Country.
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Reader looks up countries in a MaxMind database loaded from disk.
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// Open opens the MaxMind database at path.
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open geoip database: %w", err)
+	}
+	return &Reader{db: db}, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country ip belongs to,
+// or an empty string when the database does not know it.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	var record countryRecord
+	if err := r.db.Lookup(ip, &record); err != nil {
+		return "", fmt.Errorf("could not look up ip: %w", err)
+	}
+
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode, nil
+	}
+	return record.RegisteredCountry.ISOCode, nil
+}
+
+// Close releases the database.
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,208 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountry(t *testing.T) {
+	t.Parallel()
+
+	path := writeDatabaseHelper(t, map[string]map[string]any{
+		"81.2.69.0/24":    {"country": map[string]any{"iso_code": "GB"}, "registered_country": map[string]any{"iso_code": "GB"}},
+		"89.160.20.0/24":  {"registered_country": map[string]any{"iso_code": "SE"}},
+		"2001:218::/32":   {"country": map[string]any{"iso_code": "JP"}},
+		"175.16.199.0/24": {"country": map[string]any{"iso_code": "CN"}, "registered_country": map[string]any{"iso_code": "HK"}},
+	})
+
+	reader, err := Open(path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { reader.Close() })
+
+	testCases := []struct {
+		name     string
+		given    string
+		expected string
+	}{
+		{name: "country", given: "81.2.69.142", expected: "GB"},
+		{name: "country over registered country", given: "175.16.199.7", expected: "CN"},
+		{name: "registered country fallback", given: "89.160.20.112", expected: "SE"},
+		{name: "ipv6", given: "2001:218::1", expected: "JP"},
+		{name: "ipv4-mapped ipv6", given: "::ffff:81.2.69.142", expected: "GB"},
+		{name: "unknown ipv4", given: "192.0.2.1", expected: ""},
+		{name: "unknown ipv6", given: "2001:db8::1", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			observed, err := reader.Country(net.ParseIP(tc.given))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing database", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+		require.Error(t, err)
+	})
+
+	t.Run("invalid database", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "invalid.mmdb")
+		require.NoError(t, os.WriteFile(path, []byte("not a maxmind database"), 0o600))
+
+		_, err := Open(path)
+		require.Error(t, err)
+	})
+}
+
+// writeDatabaseHelper writes an IPv6 MaxMind database with 24-bit records
+// mapping each network to its record and returns its path.
+func writeDatabaseHelper(t *testing.T, records map[string]map[string]any) string {
+	t.Helper()
+
+	// Each node holds its two children: 0 for no record, a positive node
+	// index, or the negative data section offset of a record minus one.
+	nodes := [][2]int{{}}
+
+	var data bytes.Buffer
+
+	networks := make([]string, 0, len(records))
+	for network := range records {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	for _, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+
+		ip := ipNet.IP.To16()
+		ones, bits := ipNet.Mask.Size()
+		if bits == 8*net.IPv4len {
+			// IPv4 networks live in the ::/96 subtree.
+			ip = append(make(net.IP, 12), ipNet.IP.To4()...)
+			ones += 96
+		}
+
+		offset := data.Len()
+		data.Write(encodeHelper(t, records[network]))
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+
+			if i == ones-1 {
+				nodes[node][bit] = -(offset + 1)
+				break
+			}
+
+			if nodes[node][bit] <= 0 {
+				nodes = append(nodes, [2]int{})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var db bytes.Buffer
+
+	nodeCount := len(nodes)
+	for _, node := range nodes {
+		for _, record := range node {
+			value := record
+			switch {
+			case record == 0:
+				value = nodeCount
+			case record < 0:
+				value = nodeCount + 16 + (-record - 1)
+			}
+			db.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	db.Write(encodeHelper(t, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"database_type":               "Test-Country",
+		"ip_version":                  uint16(6),
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	}))
+
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	require.NoError(t, os.WriteFile(path, db.Bytes(), 0o600))
+	return path
+}
+
+// encodeHelper encodes a value in the MaxMind DB data format, supporting
+// short strings, uint16, uint32 and small maps of them.
+func encodeHelper(t *testing.T, value any) []byte {
+	t.Helper()
+
+	const (
+		typeString = 2
+		typeUint16 = 5
+		typeUint32 = 6
+		typeMap    = 7
+	)
+
+	control := func(kind, size int) byte {
+		require.Less(t, size, 29)
+		return byte(kind<<5 | size)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return append([]byte{control(typeString, len(v))}, v...)
+	case uint16:
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, v)
+		b = bytes.TrimLeft(b, "\x00")
+		return append([]byte{control(typeUint16, len(b))}, b...)
+	case uint32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		b = bytes.TrimLeft(b, "\x00")
+		return append([]byte{control(typeUint32, len(b))}, b...)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		encoded := []byte{control(typeMap, len(v))}
+		for _, key := range keys {
+			encoded = append(encoded, encodeHelper(t, key)...)
+			encoded = append(encoded, encodeHelper(t, v[key])...)
+		}
+		return encoded
+	}
+
+	t.Fatalf("cannot encode %T", value)
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package health serves liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds how long the readiness checks may take together.
+const checkTimeout = 2 * time.Second
+
+// Check reports an error when a dependency of the application is not ready.
+type Check func(ctx context.Context) error
+
+// Checker tracks whether the application is ready to serve traffic.
+// It is safe for concurrent use.
+type Checker struct {
+	mu       sync.Mutex
+	names    []string
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+// Response is the body of the readiness probe.
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// New returns a checker without checks.
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// Add registers a readiness check under name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// SetDraining marks the application as shutting down, so it reports not
+// ready while in-flight requests drain.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Live serves the liveness probe, which succeeds while the process can serve requests.
+func (c *Checker) Live(w http.ResponseWriter, req *http.Request) {
+	writeResponse(w, http.StatusOK, Response{Status: "ok"})
+}
+
+// Ready serves the readiness probe, which succeeds when every check passes
+// and the application is not draining.
+func (c *Checker) Ready(w http.ResponseWriter, req *http.Request) {
+	if c.draining.Load() {
+		writeResponse(w, http.StatusServiceUnavailable, Response{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), checkTimeout)
+	defer cancel()
+
+	c.mu.Lock()
+	names := append([]string(nil), c.names...)
+	checks := make([]Check, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, c.checks[name])
+	}
+	c.mu.Unlock()
+
+	resp := Response{Status: "ready", Checks: make(map[string]string, len(names))}
+	status := http.StatusOK
+
+	for i, check := range checks {
+		if err := check(ctx); err != nil {
+			resp.Checks[names[i]] = err.Error()
+			resp.Status = "not ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[names[i]] = "ok"
+	}
+
+	writeResponse(w, status, resp)
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	t.Parallel()
+
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("migrations at version 7, want 10") }
+
+	testCases := []struct {
+		name           string
+		checks         map[string]Check
+		draining       bool
+		expectedStatus int
+		expected       Response
+	}{
+		{
+			name:           "ready",
+			checks:         map[string]Check{"postgres": ok, "migrations": ok},
+			expectedStatus: http.StatusOK,
+			expected:       Response{Status: "ready", Checks: map[string]string{"postgres": "ok", "migrations": "ok"}},
+		},
+		{
+			name:           "failing check",
+			checks:         map[string]Check{"postgres": ok, "migrations": failing},
+			expectedStatus: http.StatusServiceUnavailable,
+			expected: Response{Status: "not ready", Checks: map[string]string{
+				"postgres":   "ok",
+				"migrations": "migrations at version 7, want 10",
+			}},
+		},
+		{
+			name:           "draining",
+			checks:         map[string]Check{"postgres": ok},
+			draining:       true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expected:       Response{Status: "draining"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checker := New()
+			for name, check := range tc.checks {
+				checker.Add(name, check)
+			}
+
+			if tc.draining {
+				checker.SetDraining()
+			}
+
+			rec := httptest.NewRecorder()
+			checker.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tc.expectedStatus, rec.Code)
+
+			var observed Response
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&observed))
+
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestLive(t *testing.T) {
+	t.Parallel()
+
+	checker := New()
+	checker.SetDraining()
+
+	rec := httptest.NewRecorder()
+	checker.Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
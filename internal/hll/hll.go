@@ -0,0 +1,107 @@
+// Package hll implements HyperLogLog sketches for approximate distinct counting.
+package hll
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// precision is the number of hash bits used to pick a register. With 2^12
+// registers a sketch takes 4KB and has a standard error of about 1.6%.
+const (
+	precision = 12
+	registers = 1 << precision
+)
+
+// ErrInvalidSketch is returned when decoding a malformed sketch.
+var ErrInvalidSketch = errors.New("invalid sketch")
+
+// Sketch estimates the number of distinct 64-bit hashes added to it.
+// Hashes must be uniformly distributed, such as a prefix of a cryptographic hash.
+type Sketch struct {
+	registers [registers]uint8
+}
+
+// New returns an empty sketch.
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add adds a hash to the sketch and reports whether the sketch changed.
+func (s *Sketch) Add(hash uint64) bool {
+	idx, rank := register(hash)
+
+	if rank <= s.registers[idx] {
+		return false
+	}
+	s.registers[idx] = rank
+	return true
+}
+
+// EncodedRegister returns the offset in the encoding of MarshalBinary of the
+// register hash falls in, and the rank adding hash raises it to. It lets a
+// stored sketch be updated in place, without decoding it.
+func EncodedRegister(hash uint64) (offset int, rank uint8) {
+	idx, rank := register(hash)
+	return 1 + int(idx), rank
+}
+
+func register(hash uint64) (uint64, uint8) {
+	idx := hash >> (64 - precision)
+
+	// The sentinel bit bounds the rank when the remaining bits are all zero.
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1)) + 1)
+	return idx, rank
+}
+
+// Merge adds the hashes counted by other to the sketch.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+}
+
+// Count returns the estimated number of distinct hashes added to the sketch.
+func (s *Sketch) Count() uint64 {
+	var (
+		sum   float64
+		zeros int
+	)
+
+	for _, rank := range s.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	const m = float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate while many registers are empty.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary encodes the sketch as its precision followed by its registers.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 1+registers)
+	data[0] = precision
+	copy(data[1:], s.registers[:])
+	return data, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) != 1+registers || data[0] != precision {
+		return ErrInvalidSketch
+	}
+	copy(s.registers[:], data[1:])
+	return nil
+}
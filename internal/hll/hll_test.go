@@ -0,0 +1,111 @@
+package hll
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		distinct int
+	}{
+		{name: "empty", distinct: 0},
+		{name: "small", distinct: 100},
+		{name: "medium", distinct: 10000},
+		{name: "large", distinct: 200000},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sketch := New()
+			for i := 0; i < tc.distinct; i++ {
+				sketch.Add(hash(fmt.Sprintf("visitor-%d", i)))
+			}
+
+			assert.InEpsilon(t, float64(tc.distinct)+1, float64(sketch.Count())+1, 0.05)
+		})
+	}
+}
+
+func TestAddDuplicates(t *testing.T) {
+	t.Parallel()
+
+	sketch := New()
+	require.True(t, sketch.Add(hash("visitor")))
+
+	for i := 0; i < 10; i++ {
+		require.False(t, sketch.Add(hash("visitor")))
+	}
+	require.Equal(t, uint64(1), sketch.Count())
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	a, b := New(), New()
+	for i := 0; i < 1000; i++ {
+		a.Add(hash(fmt.Sprintf("visitor-%d", i)))
+		b.Add(hash(fmt.Sprintf("visitor-%d", i+500)))
+	}
+
+	a.Merge(b)
+	assert.InEpsilon(t, 1500, float64(a.Count()), 0.05)
+}
+
+func TestMarshalBinary(t *testing.T) {
+	t.Parallel()
+
+	sketch := New()
+	for i := 0; i < 1000; i++ {
+		sketch.Add(hash(fmt.Sprintf("visitor-%d", i)))
+	}
+
+	data, err := sketch.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded := New()
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.Equal(t, sketch.Count(), decoded.Count())
+
+	require.ErrorIs(t, decoded.UnmarshalBinary(data[:10]), ErrInvalidSketch)
+}
+
+func TestEncodedRegister(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 100; i++ {
+		h := hash(fmt.Sprintf("visitor-%d", i))
+
+		sketch := New()
+		sketch.Add(h)
+
+		data, err := sketch.MarshalBinary()
+		require.NoError(t, err)
+
+		offset, rank := EncodedRegister(h)
+		require.Equal(t, rank, data[offset])
+
+		// Raising the register in an empty encoding gives the sketch Add gives.
+		updated, err := New().MarshalBinary()
+		require.NoError(t, err)
+
+		updated[offset] = rank
+		require.Equal(t, data, updated)
+	}
+}
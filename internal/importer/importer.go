@@ -0,0 +1,338 @@
+// Package importer reads the link exports of other URL shorteners, such as
+// Bitly and YOURLS, as CSV or JSON.
+//
+// Columns and JSON fields are recognised by name, so the CSV exports of the
+// Bitly dashboard and of the YOURLS export plugin, the links of the Bitly v4
+// API and the stats of the YOURLS API are all read the same way.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+// Formats exports can be read from.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+var (
+	// ErrUnknownFormat is returned for a format other than the supported ones.
+	ErrUnknownFormat = errors.New("unknown import format")
+
+	// ErrUnrecognizedExport is returned when an export has no short link or long URL field.
+	ErrUnrecognizedExport = errors.New("unrecognized export")
+)
+
+// Field names of each attribute of a link, normalised to lower case with
+// underscores, in order of preference.
+var (
+	codeFields      = []string{"keyword", "short_url", "shorturl", "bitly_link", "short_link", "link", "id"}
+	longURLFields   = []string{"long_url", "longurl", "url", "original_url", "destination_url"}
+	hitsFields      = []string{"clicks", "total_clicks", "hits", "engagements"}
+	createdAtFields = []string{"created_at", "created", "date_created", "creation_date", "timestamp"}
+
+	// customCodesField lists the custom back-halves of a Bitly link, which
+	// are imported as links of their own.
+	customCodesField = "custom_bitlinks"
+)
+
+// timeLayouts are the layouts creation times are parsed with. Times without
+// a zone, as exported by YOURLS, are taken as UTC.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Read reads the links of an export in format.
+func Read(r io.Reader, format string) ([]service.ImportRecord, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatJSON:
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("%q: %w", format, ErrUnknownFormat)
+}
+
+func readCSV(r io.Reader) ([]service.ImportRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("empty csv: %w", ErrUnrecognizedExport)
+		}
+		return nil, fmt.Errorf("could not read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	present := make(map[string]bool, len(header))
+	for i, name := range header {
+		// Spreadsheet applications prefix UTF-8 files with a byte order mark.
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		if key := normalize(name); key != "" {
+			if !present[key] {
+				columns[key] = i
+				present[key] = true
+			}
+		}
+	}
+
+	if !hasAny(present, codeFields) || !hasAny(present, longURLFields) {
+		return nil, fmt.Errorf("csv header %q: %w", strings.Join(header, ","), ErrUnrecognizedExport)
+	}
+
+	var records []service.ImportRecord
+	for {
+		row, err := cr.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, fmt.Errorf("could not read csv: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		fields := make(map[string]string, len(columns))
+		for key, i := range columns {
+			if i < len(row) {
+				fields[key] = strings.TrimSpace(row[i])
+			}
+		}
+
+		record, err := newRecord(line, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+}
+
+func readJSON(r io.Reader) ([]service.ImportRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read json: %w", err)
+	}
+
+	links, err := decodeLinks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []service.ImportRecord
+	for i, link := range links {
+		position := i + 1
+
+		fields := make(map[string]string, len(link))
+		present := make(map[string]bool, len(link))
+		for name, value := range link {
+			if key := normalize(name); key != customCodesField {
+				fields[key] = jsonString(value)
+				present[key] = true
+			}
+		}
+
+		if !hasAny(present, codeFields) || !hasAny(present, longURLFields) {
+			return nil, fmt.Errorf("link %d: %w", position, ErrUnrecognizedExport)
+		}
+
+		record, err := newRecord(position, fields)
+		if err != nil {
+			return nil, fmt.Errorf("link %d: %w", position, err)
+		}
+		records = append(records, record)
+
+		for _, custom := range customCodes(link) {
+			alias := record
+			alias.Code = custom
+			records = append(records, alias)
+		}
+	}
+	return records, nil
+}
+
+// decodeLinks decodes the links of a JSON export, which are either an array
+// of objects or the "links" field of an object. YOURLS lists links as an
+// object keyed by link_1, link_2 and so on, which is read in order.
+func decodeLinks(data []byte) ([]map[string]any, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty json: %w", ErrUnrecognizedExport)
+	}
+
+	if data[0] == '[' {
+		var links []map[string]any
+		if err := unmarshal(data, &links); err != nil {
+			return nil, fmt.Errorf("could not decode json links: %w", err)
+		}
+		return links, nil
+	}
+
+	var export struct {
+		Links json.RawMessage `json:"links"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("could not decode json: %w", err)
+	}
+
+	links := bytes.TrimSpace(export.Links)
+	if len(links) == 0 {
+		return nil, fmt.Errorf("no links field: %w", ErrUnrecognizedExport)
+	}
+
+	if links[0] == '[' {
+		return decodeLinks(links)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(links))
+	dec.UseNumber()
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("could not decode json links: %w", err)
+	}
+
+	var result []map[string]any
+	for dec.More() {
+		// Skip the key.
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("could not decode json links: %w", err)
+		}
+
+		var link map[string]any
+		if err := dec.Decode(&link); err != nil {
+			return nil, fmt.Errorf("could not decode json links: %w", err)
+		}
+		result = append(result, link)
+	}
+	return result, nil
+}
+
+// unmarshal decodes data into v, keeping numbers as json.Number.
+func unmarshal(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// newRecord builds the record at position from the normalised fields of a link.
+func newRecord(position int, fields map[string]string) (service.ImportRecord, error) {
+	record := service.ImportRecord{
+		Position: position,
+		Code:     code(first(fields, codeFields)),
+		LongURL:  first(fields, longURLFields),
+	}
+
+	if hits := first(fields, hitsFields); hits != "" {
+		n, err := strconv.Atoi(hits)
+		if err != nil {
+			return service.ImportRecord{}, fmt.Errorf("invalid clicks %q", hits)
+		}
+		record.Hits = n
+	}
+
+	if createdAt := first(fields, createdAtFields); createdAt != "" {
+		t, err := parseTime(createdAt)
+		if err != nil {
+			return service.ImportRecord{}, err
+		}
+		record.CreatedAt = &t
+	}
+	return record, nil
+}
+
+// code returns the code of a short link, which may be a bare code such as a
+// YOURLS keyword or a link such as https://bit.ly/3xYzAb or bit.ly/3xYzAb.
+func code(link string) string {
+	link = strings.TrimSuffix(link, "/")
+	if i := strings.LastIndex(link, "/"); i >= 0 {
+		return link[i+1:]
+	}
+	return link
+}
+
+// customCodes returns the codes of the custom back-halves of a Bitly link.
+func customCodes(link map[string]any) []string {
+	var codes []string
+	for name, value := range link {
+		if normalize(name) != customCodesField {
+			continue
+		}
+
+		values, _ := value.([]any)
+		for _, v := range values {
+			if s, ok := v.(string); ok && s != "" {
+				codes = append(codes, code(s))
+			}
+		}
+	}
+	return codes
+}
+
+func parseTime(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid creation time %q", s)
+}
+
+// normalize returns a field name in lower case with underscores.
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// hasAny reports whether any of names is present.
+func hasAny(present map[string]bool, names []string) bool {
+	for _, name := range names {
+		if present[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// first returns the first non-empty value of names in fields.
+func first(fields map[string]string, names []string) string {
+	for _, name := range names {
+		if v := fields[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// jsonString returns a JSON value as a string, as YOURLS exports numbers as
+// strings and Bitly does not.
+func jsonString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alesr/urltinyizer/internal/service"
+)
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	date := func(s string) *time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return &d
+	}
+
+	testCases := []struct {
+		name     string
+		format   string
+		export   string
+		expected []service.ImportRecord
+	}{
+		{
+			name:   "bitly csv",
+			format: FormatCSV,
+			export: "\ufeffTitle,Long URL,Bitly Link,Created,Clicks\n" +
+				"Foo,https://www.foo.com/a,https://bit.ly/3xYzAb,2023-03-01T12:00:00+0000,12\n" +
+				"\"Bar, baz\",https://www.foo.com/b,bit.ly/promo,2023-03-02T08:30:00Z,\n",
+			expected: []service.ImportRecord{
+				{Position: 2, Code: "3xYzAb", LongURL: "https://www.foo.com/a", Hits: 12, CreatedAt: date("2023-03-01T12:00:00Z")},
+				{Position: 3, Code: "promo", LongURL: "https://www.foo.com/b", CreatedAt: date("2023-03-02T08:30:00Z")},
+			},
+		},
+		{
+			name:   "yourls csv",
+			format: FormatCSV,
+			export: "keyword,url,title,timestamp,ip,clicks\n" +
+				"abc,https://www.foo.com/a,Foo,2023-03-01 12:00:00,127.0.0.1,7\n",
+			expected: []service.ImportRecord{
+				{Position: 2, Code: "abc", LongURL: "https://www.foo.com/a", Hits: 7, CreatedAt: date("2023-03-01T12:00:00Z")},
+			},
+		},
+		{
+			name:   "bitly json",
+			format: FormatJSON,
+			export: `{"links": [
+				{"id": "bit.ly/3xYzAb", "link": "https://bit.ly/3xYzAb", "long_url": "https://www.foo.com/a", "created_at": "2023-03-01T12:00:00+0000", "custom_bitlinks": ["https://bit.ly/promo"]},
+				{"id": "bit.ly/4aBc", "link": "https://bit.ly/4aBc", "long_url": "https://www.foo.com/b", "created_at": "2023-03-02T08:30:00+0000", "custom_bitlinks": []}
+			], "pagination": {"total": 2}}`,
+			expected: []service.ImportRecord{
+				{Position: 1, Code: "3xYzAb", LongURL: "https://www.foo.com/a", CreatedAt: date("2023-03-01T12:00:00Z")},
+				{Position: 1, Code: "promo", LongURL: "https://www.foo.com/a", CreatedAt: date("2023-03-01T12:00:00Z")},
+				{Position: 2, Code: "4aBc", LongURL: "https://www.foo.com/b", CreatedAt: date("2023-03-02T08:30:00Z")},
+			},
+		},
+		{
+			name:   "yourls json",
+			format: FormatJSON,
+			export: `{"links": {
+				"link_1": {"shorturl": "https://sho.rt/abc", "url": "https://www.foo.com/a", "timestamp": "2023-03-01 12:00:00", "clicks": "7"},
+				"link_2": {"shorturl": "https://sho.rt/def", "url": "https://www.foo.com/b", "timestamp": "2023-03-02 08:30:00", "clicks": 3}
+			}, "stats": {"total_links": "2"}}`,
+			expected: []service.ImportRecord{
+				{Position: 1, Code: "abc", LongURL: "https://www.foo.com/a", Hits: 7, CreatedAt: date("2023-03-01T12:00:00Z")},
+				{Position: 2, Code: "def", LongURL: "https://www.foo.com/b", Hits: 3, CreatedAt: date("2023-03-02T08:30:00Z")},
+			},
+		},
+		{
+			name:   "json array",
+			format: FormatJSON,
+			export: `[{"keyword": "abc", "url": "https://www.foo.com/a"}]`,
+			expected: []service.ImportRecord{
+				{Position: 1, Code: "abc", LongURL: "https://www.foo.com/a"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			records, err := Read(strings.NewReader(tc.export), tc.format)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, records)
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		format string
+		export string
+		err    error
+		msg    string
+	}{
+		{
+			name:   "unknown format",
+			format: "xml",
+			err:    ErrUnknownFormat,
+		},
+		{
+			name:   "csv without long urls",
+			format: FormatCSV,
+			export: "keyword,title\nabc,Foo\n",
+			err:    ErrUnrecognizedExport,
+		},
+		{
+			name:   "json without links",
+			format: FormatJSON,
+			export: `{"status": "ok"}`,
+			err:    ErrUnrecognizedExport,
+		},
+		{
+			name:   "invalid clicks",
+			format: FormatCSV,
+			export: "keyword,url,clicks\nabc,https://www.foo.com/a,many\n",
+			msg:    `line 2: invalid clicks "many"`,
+		},
+		{
+			name:   "invalid creation time",
+			format: FormatJSON,
+			export: `[{"keyword": "abc", "url": "https://www.foo.com/a", "timestamp": "yesterday"}]`,
+			msg:    `link 1: invalid creation time "yesterday"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Read(strings.NewReader(tc.export), tc.format)
+			require.Error(t, err)
+
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			}
+			if tc.msg != "" {
+				assert.EqualError(t, err, tc.msg)
+			}
+		})
+	}
+}
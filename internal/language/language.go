@@ -0,0 +1,103 @@
+// Package language parses Accept-Language headers and matches them against
+// a set of available language tags.
+package language
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z]{1,8}(-[a-z0-9]{1,8})*$`)
+
+// Preference is a language range from an Accept-Language header with its quality value.
+type Preference struct {
+	Tag     string
+	Quality float64
+}
+
+// ValidTag reports whether tag is a well-formed language tag such as "en" or "pt-br".
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(strings.ToLower(tag))
+}
+
+// ParseAcceptLanguage parses an Accept-Language header into preferences ordered
+// by descending quality. Ranges with the same quality keep their header order,
+// and ranges with a quality of zero or a malformed entry are dropped.
+func ParseAcceptLanguage(header string) []Preference {
+	var prefs []Preference
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag != "*" && !tagPattern.MatchString(tag) {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				quality = 0
+				break
+			}
+			quality = q
+		}
+
+		if quality == 0 {
+			continue
+		}
+		prefs = append(prefs, Preference{Tag: tag, Quality: quality})
+	}
+
+	sort.SliceStable(prefs, func(i, j int) bool {
+		return prefs[i].Quality > prefs[j].Quality
+	})
+	return prefs
+}
+
+// Match returns the available tag that best satisfies the preferences.
+// For each preference, in order, an exact match wins over the preference's
+// base language ("pt" for "pt-br"), which wins over a regional variant of it
+// ("pt-br" for "pt"). The wildcard "*" never selects a tag, leaving the caller
+// to fall back to its default.
+func Match(prefs []Preference, available []string) (string, bool) {
+	for _, pref := range prefs {
+		if pref.Tag == "*" {
+			continue
+		}
+
+		base := baseLanguage(pref.Tag)
+
+		for _, tag := range available {
+			if strings.EqualFold(tag, pref.Tag) {
+				return tag, true
+			}
+		}
+
+		for _, tag := range available {
+			if strings.EqualFold(tag, base) {
+				return tag, true
+			}
+		}
+
+		for _, tag := range available {
+			if strings.EqualFold(baseLanguage(tag), base) {
+				return tag, true
+			}
+		}
+	}
+	return "", false
+}
+
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return strings.ToLower(base)
+}
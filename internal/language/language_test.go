@@ -0,0 +1,79 @@
+package language
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		given    string
+		expected []Preference
+	}{
+		{
+			name:  "ordered by quality",
+			given: "fr;q=0.5, pt-BR, en-US;q=0.8, en;q=0.8",
+			expected: []Preference{
+				{Tag: "pt-br", Quality: 1},
+				{Tag: "en-us", Quality: 0.8},
+				{Tag: "en", Quality: 0.8},
+				{Tag: "fr", Quality: 0.5},
+			},
+		},
+		{
+			name:  "drops zero quality and malformed entries",
+			given: "de;q=0, es;q=abc, it;q=2, ;q=0.3, *;q=0.1",
+			expected: []Preference{
+				{Tag: "*", Quality: 0.1},
+			},
+		},
+		{
+			name:     "empty header",
+			given:    "",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, ParseAcceptLanguage(tc.given))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	available := []string{"en", "pt-br", "pt", "fr-ca"}
+
+	testCases := []struct {
+		name     string
+		given    string
+		expected string
+		ok       bool
+	}{
+		{name: "exact", given: "pt-BR,en;q=0.5", expected: "pt-br", ok: true},
+		{name: "base language", given: "pt-PT,en;q=0.5", expected: "pt", ok: true},
+		{name: "regional variant", given: "fr,en;q=0.5", expected: "fr-ca", ok: true},
+		{name: "preference order", given: "de,en;q=0.9,pt;q=0.8", expected: "en", ok: true},
+		{name: "no match", given: "de,ja;q=0.5,*;q=0.1", ok: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			observed, ok := Match(ParseAcceptLanguage(tc.given), available)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, observed)
+		})
+	}
+}
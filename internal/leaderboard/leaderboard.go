@@ -0,0 +1,114 @@
+// Package leaderboard counts hits per key in a sliding time window, in memory.
+package leaderboard
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is the number of hits of a key within a window.
+type Entry struct {
+	Key  string
+	Hits int
+}
+
+// Counter counts hits in buckets of a fixed resolution, keeping the buckets
+// of the retention period in a ring. It is safe for concurrent use.
+type Counter struct {
+	mu         sync.Mutex
+	resolution time.Duration
+	buckets    []bucket
+}
+
+type bucket struct {
+	slot int64
+	hits map[string]int
+}
+
+// New returns a counter able to report windows of up to retention, with
+// the given resolution.
+func New(resolution, retention time.Duration) *Counter {
+	n := int(retention / resolution)
+	if n < 1 {
+		n = 1
+	}
+	return &Counter{resolution: resolution, buckets: make([]bucket, n)}
+}
+
+// Retention returns the longest window the counter can report.
+func (c *Counter) Retention() time.Duration {
+	return time.Duration(len(c.buckets)) * c.resolution
+}
+
+// Add records a hit of key at t.
+func (c *Counter) Add(key string, t time.Time) {
+	slot := c.slot(t)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := &c.buckets[c.index(slot)]
+	if b.slot != slot || b.hits == nil {
+		// The bucket held an older slot that has fallen out of the retention period.
+		b.slot = slot
+		b.hits = make(map[string]int)
+	}
+	b.hits[key]++
+}
+
+// Top returns the keys with the most hits in the window ending at now, at
+// most limit of them. The window is rounded up to the resolution and capped
+// at the retention period.
+func (c *Counter) Top(now time.Time, window time.Duration, limit int) []Entry {
+	last := c.slot(now)
+
+	slots := int64((window + c.resolution - 1) / c.resolution)
+	if slots > int64(len(c.buckets)) {
+		slots = int64(len(c.buckets))
+	}
+
+	totals := make(map[string]int)
+
+	c.mu.Lock()
+	for slot := last - slots + 1; slot <= last; slot++ {
+		b := c.buckets[c.index(slot)]
+		if b.slot != slot {
+			continue
+		}
+
+		for key, hits := range b.hits {
+			totals[key] += hits
+		}
+	}
+	c.mu.Unlock()
+
+	entries := make([]Entry, 0, len(totals))
+	for key, hits := range totals {
+		entries = append(entries, Entry{Key: key, Hits: hits})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Hits != entries[j].Hits {
+			return entries[i].Hits > entries[j].Hits
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func (c *Counter) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(c.resolution)
+}
+
+func (c *Counter) index(slot int64) int {
+	i := int(slot % int64(len(c.buckets)))
+	if i < 0 {
+		i += len(c.buckets)
+	}
+	return i
+}
@@ -0,0 +1,78 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTop(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	counter := New(time.Minute, 24*time.Hour)
+
+	counter.Add("a", now.Add(-30*time.Hour)) // Beyond the retention period.
+	counter.Add("a", now.Add(-2*time.Hour))
+	counter.Add("b", now.Add(-2*time.Hour))
+	counter.Add("b", now.Add(-30*time.Minute))
+	counter.Add("b", now)
+	counter.Add("c", now.Add(-10*time.Minute))
+	counter.Add("c", now.Add(-5*time.Minute))
+	counter.Add("d", now.Add(-5*time.Minute))
+
+	testCases := []struct {
+		name     string
+		window   time.Duration
+		limit    int
+		expected []Entry
+	}{
+		{
+			name:     "last hour",
+			window:   time.Hour,
+			limit:    10,
+			expected: []Entry{{Key: "b", Hits: 2}, {Key: "c", Hits: 2}, {Key: "d", Hits: 1}},
+		},
+		{
+			name:     "last day",
+			window:   24 * time.Hour,
+			limit:    2,
+			expected: []Entry{{Key: "b", Hits: 3}, {Key: "c", Hits: 2}},
+		},
+		{
+			name:     "window beyond retention",
+			window:   48 * time.Hour,
+			limit:    1,
+			expected: []Entry{{Key: "b", Hits: 3}},
+		},
+		{
+			name:     "last minute",
+			window:   time.Minute,
+			limit:    10,
+			expected: []Entry{{Key: "b", Hits: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.expected, counter.Top(now, tc.window, tc.limit))
+		})
+	}
+}
+
+func TestAddReusesExpiredBuckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	counter := New(time.Minute, time.Hour)
+	counter.Add("a", now.Add(-time.Hour))
+	counter.Add("b", now)
+
+	require.Equal(t, []Entry{{Key: "b", Hits: 1}}, counter.Top(now, time.Hour, 10))
+}
@@ -0,0 +1,144 @@
+// Package metrics collects the Prometheus metrics of the application.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "urltinyizer"
+
+// Outcomes of a redirect request.
+const (
+	RedirectFound    = "found"
+	RedirectNotFound = "not_found"
+	RedirectExpired  = "expired"
+	RedirectBlocked  = "blocked"
+	RedirectError    = "error"
+)
+
+// UnmatchedRoute labels requests that matched no route, so that arbitrary
+// paths do not create new series.
+const UnmatchedRoute = "unmatched"
+
+// Metrics holds the collectors of the application in their own registry.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	redirects       *prometheus.CounterVec
+	linksCreated    prometheus.Counter
+	queryDuration   *prometheus.HistogramVec
+}
+
+// New creates the collectors and registers them, along with the Go runtime
+// and process collectors, in a new registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "redirects_total",
+			Help:      "Number of redirect requests by outcome.",
+		}, []string{"outcome"}),
+		linksCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "links_created_total",
+			Help:      "Number of short URLs created.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Latency of repository operations by method and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.redirects,
+		m.linksCreated,
+		m.queryDuration,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times requests by the chi route pattern they matched.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+
+		next.ServeHTTP(ww, req)
+
+		route := RoutePattern(req)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.requests.WithLabelValues(route, req.Method, strconv.Itoa(status)).Inc()
+		m.requestDuration.WithLabelValues(route, req.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RoutePattern returns the chi route pattern req matched, or UnmatchedRoute.
+// It is only known once the router has served req.
+func RoutePattern(req *http.Request) string {
+	if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return UnmatchedRoute
+}
+
+// ObserveRedirect counts a redirect request with the given outcome.
+func (m *Metrics) ObserveRedirect(outcome string) {
+	m.redirects.WithLabelValues(outcome).Inc()
+}
+
+// ObserveLinkCreated counts a created short URL.
+func (m *Metrics) ObserveLinkCreated() {
+	m.linksCreated.Inc()
+}
+
+// ObserveQuery records the latency of a repository operation.
+func (m *Metrics) ObserveQuery(method string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.queryDuration.WithLabelValues(method, result).Observe(d.Seconds())
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+
+	router := chi.NewRouter()
+	router.Use(m.Middleware)
+	router.Get("/{shortURL}", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "https://www.foo.com", http.StatusFound)
+	})
+
+	for _, path := range []string{"/abc", "/def", "/abc/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("/{shortURL}", http.MethodGet, "302")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues(UnmatchedRoute, http.MethodGet, "404")))
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	m := New()
+	m.ObserveRedirect(RedirectExpired)
+	m.ObserveLinkCreated()
+	m.ObserveQuery("GetURL", 3*time.Millisecond, nil)
+	m.ObserveQuery("GetURL", time.Millisecond, errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	for _, expected := range []string{
+		`urltinyizer_redirects_total{outcome="expired"} 1`,
+		`urltinyizer_links_created_total 1`,
+		`urltinyizer_db_query_duration_seconds_count{method="GetURL",result="ok"} 1`,
+		`urltinyizer_db_query_duration_seconds_count{method="GetURL",result="error"} 1`,
+		`go_goroutines`,
+	} {
+		assert.True(t, strings.Contains(string(body), expected), "missing %s", expected)
+	}
+}
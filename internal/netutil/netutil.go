@@ -0,0 +1,32 @@
+// Package netutil parses the IP addresses and CIDR networks of configured
+// address lists.
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseNetwork parses a CIDR network or a bare IP address, which is taken as
+// a network of that single address (/32 or /128).
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip address %q", s)
+		}
+
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
@@ -0,0 +1,42 @@
+package netutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNetwork(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		given       string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "ipv4 address", given: "192.0.2.1", expected: "192.0.2.1/32"},
+		{name: "ipv6 address", given: "2001:db8::1", expected: "2001:db8::1/128"},
+		{name: "ipv4 network", given: "10.0.0.0/8", expected: "10.0.0.0/8"},
+		{name: "ipv6 network", given: "2001:db8::/32", expected: "2001:db8::/32"},
+		{name: "invalid address", given: "not-an-ip", expectedErr: true},
+		{name: "invalid network", given: "10.0.0.0/33", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			observed, err := ParseNetwork(tc.given)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, observed.String())
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package qrcode
+
+import "sync"
+
+// Cache is a bounded in-memory cache of rendered QR codes.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string][]byte
+}
+
+// NewCache creates a cache holding at most maxEntries images.
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    make(map[string][]byte),
+	}
+}
+
+// Get returns the cached image for key, if any.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	img, ok := c.entries[key]
+	return img, ok
+}
+
+// Set stores img under key, evicting an arbitrary entry when the cache is full.
+func (c *Cache) Set(key string, img []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = img
+}
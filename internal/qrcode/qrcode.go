@@ -0,0 +1,124 @@
+// Package qrcode renders QR codes as PNG or SVG images.
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Level is the error correction level of a QR code.
+type Level = qr.Level
+
+// Error correction levels, from lowest to highest redundancy.
+const (
+	LevelL = qr.L
+	LevelM = qr.M
+	LevelQ = qr.Q
+	LevelH = qr.H
+)
+
+// ParseLevel parses an error correction level from its letter (L, M, Q or H).
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return LevelL, nil
+	case "M":
+		return LevelM, nil
+	case "Q":
+		return LevelQ, nil
+	case "H":
+		return LevelH, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", s)
+}
+
+// Options controls how a QR code is rendered.
+type Options struct {
+	// Size is the width and height of the image in pixels.
+	Size int
+	// Level is the error correction level.
+	Level Level
+	// Margin is the width of the quiet zone around the code, in modules.
+	Margin int
+}
+
+// PNG encodes text as a QR code and renders it as a PNG image.
+func PNG(text string, opts Options) ([]byte, error) {
+	code, err := qr.Encode(text, opts.Level)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode qr code: %w", err)
+	}
+
+	modules := code.Size + 2*opts.Margin
+	scale := opts.Size / modules
+	if scale < 1 {
+		scale = 1
+	}
+
+	// Center the code when the requested size is not a multiple of the module count.
+	size := opts.Size
+	if size < modules*scale {
+		size = modules * scale
+	}
+	offset := (size - modules*scale) / 2
+
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			px := offset + (x+opts.Margin)*scale
+			py := offset + (y+opts.Margin)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray(px+dx, py+dy, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("could not encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG encodes text as a QR code and renders it as an SVG image.
+func SVG(text string, opts Options) ([]byte, error) {
+	code, err := qr.Encode(text, opts.Level)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode qr code: %w", err)
+	}
+
+	modules := code.Size + 2*opts.Margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules,
+	)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPNG(t *testing.T) {
+	t.Parallel()
+
+	observed, err := PNG("http://foo.com/595c3c", Options{Size: 256, Level: LevelM, Margin: 4})
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(observed))
+	require.NoError(t, err)
+
+	assert.Equal(t, 256, img.Bounds().Dx())
+	assert.Equal(t, 256, img.Bounds().Dy())
+}
+
+func TestSVG(t *testing.T) {
+	t.Parallel()
+
+	observed, err := SVG("http://foo.com/595c3c", Options{Size: 256, Level: LevelH, Margin: 0})
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(observed, []byte("<svg")))
+	assert.Contains(t, string(observed), `width="256"`)
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	level, err := ParseLevel("q")
+	require.NoError(t, err)
+	assert.Equal(t, LevelQ, level)
+
+	_, err = ParseLevel("X")
+	require.Error(t, err)
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache(1)
+	c.Set("a", []byte("a"))
+	c.Set("b", []byte("b"))
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	img, ok := c.Get("b")
+	require.True(t, ok)
+	assert.Equal(t, []byte("b"), img)
+}
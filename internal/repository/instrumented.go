@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+var _ Repository = (*Instrumented)(nil)
+
+// QueryObserver is called with the duration and error of each repository operation.
+type QueryObserver func(method string, d time.Duration, err error)
+
+// Instrumented is a repository reporting the latency of the operations of another.
+type Instrumented struct {
+	repo    Repository
+	observe QueryObserver
+}
+
+// NewInstrumented wraps repo, calling observe after each of its operations.
+func NewInstrumented(repo Repository, observe QueryObserver) *Instrumented {
+	return &Instrumented{repo: repo, observe: observe}
+}
+
+func (i *Instrumented) GetShortURL(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+	start := time.Now()
+	result, err := i.repo.GetShortURL(ctx, longURL, redirectStatus)
+	i.observe("GetShortURL", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	start := time.Now()
+	result, err := i.repo.GetURL(ctx, shortURL)
+	i.observe("GetURL", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) RecordHit(ctx context.Context, hit Hit) error {
+	start := time.Now()
+	err := i.repo.RecordHit(ctx, hit)
+	i.observe("RecordHit", time.Since(start), err)
+	return err
+}
+
+func (i *Instrumented) RecordBotHit(ctx context.Context, shortURL string) error {
+	start := time.Now()
+	err := i.repo.RecordBotHit(ctx, shortURL)
+	i.observe("RecordBotHit", time.Since(start), err)
+	return err
+}
+
+func (i *Instrumented) GetStats(ctx context.Context, shortURL string) (Stats, error) {
+	start := time.Now()
+	result, err := i.repo.GetStats(ctx, shortURL)
+	i.observe("GetStats", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) SaveShortURL(ctx context.Context, url URL) error {
+	start := time.Now()
+	err := i.repo.SaveShortURL(ctx, url)
+	i.observe("SaveShortURL", time.Since(start), err)
+	return err
+}
+
+func (i *Instrumented) SaveRule(ctx context.Context, rule Rule) (Rule, error) {
+	start := time.Now()
+	result, err := i.repo.SaveRule(ctx, rule)
+	i.observe("SaveRule", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	start := time.Now()
+	result, err := i.repo.ListRules(ctx, shortURL)
+	i.observe("ListRules", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) DeleteRule(ctx context.Context, shortURL string, id int64) (bool, error) {
+	start := time.Now()
+	result, err := i.repo.DeleteRule(ctx, shortURL, id)
+	i.observe("DeleteRule", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) ReplaceVariants(ctx context.Context, shortURL string, sticky bool, variants []Variant) ([]Variant, error) {
+	start := time.Now()
+	result, err := i.repo.ReplaceVariants(ctx, shortURL, sticky, variants)
+	i.observe("ReplaceVariants", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) ListVariants(ctx context.Context, shortURL string) ([]Variant, error) {
+	start := time.Now()
+	result, err := i.repo.ListVariants(ctx, shortURL)
+	i.observe("ListVariants", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) RecordVariantHit(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := i.repo.RecordVariantHit(ctx, id)
+	i.observe("RecordVariantHit", time.Since(start), err)
+	return err
+}
+
+func (i *Instrumented) AddVisitor(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+	start := time.Now()
+	err := i.repo.AddVisitor(ctx, shortURL, day, hash)
+	i.observe("AddVisitor", time.Since(start), err)
+	return err
+}
+
+func (i *Instrumented) ListVisitorSketches(ctx context.Context, shortURL string) ([]VisitorSketch, error) {
+	start := time.Now()
+	result, err := i.repo.ListVisitorSketches(ctx, shortURL)
+	i.observe("ListVisitorSketches", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) TopSources(ctx context.Context, query TopSourcesQuery) ([]SourceCount, error) {
+	start := time.Now()
+	result, err := i.repo.TopSources(ctx, query)
+	i.observe("TopSources", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) HitSeries(ctx context.Context, query HitSeriesQuery) ([]Bucket, error) {
+	start := time.Now()
+	result, err := i.repo.HitSeries(ctx, query)
+	i.observe("HitSeries", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) DeleteURL(ctx context.Context, shortURL string) (bool, error) {
+	start := time.Now()
+	result, err := i.repo.DeleteURL(ctx, shortURL)
+	i.observe("DeleteURL", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) SaveAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	start := time.Now()
+	result, err := i.repo.SaveAPIKey(ctx, key)
+	i.observe("SaveAPIKey", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (APIKey, error) {
+	start := time.Now()
+	result, err := i.repo.GetAPIKeyByHash(ctx, keyHash)
+	i.observe("GetAPIKeyByHash", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	start := time.Now()
+	result, err := i.repo.RevokeAPIKey(ctx, id)
+	i.observe("RevokeAPIKey", time.Since(start), err)
+	return result, err
+}
+
+func (i *Instrumented) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	start := time.Now()
+	err := i.repo.ExportURLs(ctx, ownerKeyID, fn)
+	i.observe("ExportURLs", time.Since(start), err)
+	return err
+}
+
+func (i *Instrumented) ImportURL(ctx context.Context, url URL) (bool, error) {
+	start := time.Now()
+	result, err := i.repo.ImportURL(ctx, url)
+	i.observe("ImportURL", time.Since(start), err)
+	return result, err
+}
@@ -5,25 +5,64 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/alesr/urltinyizer/internal/hll"
+	"github.com/alesr/urltinyizer/internal/requestid"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 const (
-	getShortURLQuery            string = "SELECT short_url FROM urls WHERE long_url = $1"
-	getLongURLQuery             string = "SELECT long_url FROM urls WHERE short_url = $1"
-	geStatsQuery                string = "SELECT hits FROM urls WHERE short_url = $1"
+	getShortURLQuery            string = "SELECT short_url FROM urls WHERE long_url = $1 AND redirect_status = $2 AND password_hash IS NULL AND not_before IS NULL AND not_after IS NULL AND owner_key_id IS NULL"
+	getURLQuery                 string = "SELECT short_url, long_url, redirect_status, COALESCE(password_hash, '') AS password_hash, not_before, not_after, sticky_variants, hits, created_at, owner_key_id FROM urls WHERE short_url = $1"
+	geStatsQuery                string = "SELECT hits, bot_hits FROM urls WHERE short_url = $1"
 	updateHitsAndLastHitAtQuery string = "UPDATE urls SET hits = hits + 1, last_hit_at = NOW() WHERE short_url = $1"
-	saveShortURLQuery           string = "INSERT INTO urls (short_url, long_url) VALUES ($1, $2)"
+	insertHitEventQuery         string = "INSERT INTO hit_events (short_url, occurred_at, referrer_domain, utm_source, ua_family) VALUES (:short_url, :occurred_at, :referrer_domain, :utm_source, :ua_family)"
+	updateBotHitsQuery          string = "UPDATE urls SET bot_hits = bot_hits + 1 WHERE short_url = $1"
+	saveRuleQuery               string = "INSERT INTO redirect_rules (short_url, kind, condition, target_url, priority) VALUES ($1, $2, $3, $4, $5) RETURNING id, short_url, kind, condition, target_url, priority, created_at"
+	listRulesQuery              string = "SELECT id, short_url, kind, condition, target_url, priority, created_at FROM redirect_rules WHERE short_url = $1 ORDER BY priority, id"
+	deleteRuleQuery             string = "DELETE FROM redirect_rules WHERE short_url = $1 AND id = $2"
+	deleteDroppedVariantsQuery  string = "DELETE FROM url_variants WHERE short_url = $1 AND NOT (target_url = ANY($2))"
+	saveVariantQuery            string = "INSERT INTO url_variants (short_url, target_url, weight) VALUES ($1, $2, $3) ON CONFLICT (short_url, md5(target_url)) DO UPDATE SET weight = EXCLUDED.weight RETURNING id, short_url, target_url, weight, hits, created_at"
+	updateStickyVariantsQuery   string = "UPDATE urls SET sticky_variants = $2 WHERE short_url = $1"
+	listVariantsQuery           string = "SELECT id, short_url, target_url, weight, hits, created_at FROM url_variants WHERE short_url = $1 ORDER BY id"
+	updateVariantHitsQuery      string = "UPDATE url_variants SET hits = hits + 1 WHERE id = $1"
+	addVisitorQuery             string = "INSERT INTO visitor_sketches (short_url, day, sketch) VALUES ($1, $2, $3) ON CONFLICT (short_url, day) DO UPDATE SET sketch = set_byte(visitor_sketches.sketch, $4, $5) WHERE get_byte(visitor_sketches.sketch, $4) < $5"
+	listVisitorSketchesQuery    string = "SELECT day, sketch FROM visitor_sketches WHERE short_url = $1 ORDER BY day"
+	topSourcesQuery             string = "SELECT %[1]s AS value, COUNT(*) AS hits FROM hit_events WHERE short_url = $1 AND occurred_at >= $2 AND occurred_at < $3 AND %[1]s <> '' GROUP BY %[1]s ORDER BY hits DESC, value LIMIT $4 OFFSET $5"
+	hitSeriesQuery              string = "SELECT date_trunc($4, occurred_at AT TIME ZONE $5) AS start, COUNT(*) AS hits FROM hit_events WHERE short_url = $1 AND occurred_at >= $2 AND occurred_at < $3 GROUP BY start ORDER BY start"
+	deleteURLQuery              string = "DELETE FROM urls WHERE short_url = $1"
+	saveAPIKeyQuery             string = "INSERT INTO api_keys (name, key_hash) VALUES ($1, $2) RETURNING id, name, key_hash, created_at, revoked_at"
+	getAPIKeyByHashQuery        string = "SELECT id, name, key_hash, created_at, revoked_at FROM api_keys WHERE key_hash = $1"
+	revokeAPIKeyQuery           string = "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1"
+	declareExportCursorQuery    string = "DECLARE export_urls NO SCROLL CURSOR FOR SELECT short_url, long_url, created_at, hits, last_hit_at FROM urls WHERE %[1]d = 0 OR owner_key_id = %[1]d ORDER BY created_at, short_url"
+	fetchExportCursorQuery      string = "FETCH 1000 FROM export_urls"
+	saveShortURLQuery           string = "INSERT INTO urls (short_url, long_url, redirect_status, password_hash, not_before, not_after, owner_key_id) VALUES (:short_url, :long_url, :redirect_status, NULLIF(:password_hash, ''), :not_before, :not_after, :owner_key_id)"
+	importURLQuery              string = "INSERT INTO urls (short_url, long_url, redirect_status, hits, created_at, owner_key_id) VALUES (:short_url, :long_url, :redirect_status, :hits, :created_at, :owner_key_id) ON CONFLICT (short_url) DO NOTHING"
 )
 
+// PostgreSQL error codes of constraint violations.
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// dimensionColumns holds the hit_events columns hits can be grouped by.
+var dimensionColumns = map[Dimension]bool{
+	DimensionReferrer:        true,
+	DimensionUTMSource:       true,
+	DimensionUserAgentFamily: true,
+}
+
 // DB defines a interface with the methods from sqlx.DB struct.
 type db interface {
 	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
 }
 
 // PostgreSQL is a repository that implements the Repository interface.
@@ -40,10 +79,10 @@ func NewPostgreSQL(logger *zap.Logger, dbConn db) *PostgreSQL {
 	}
 }
 
-// GetShortURL returns the short URL for a given long URL.
-func (p *PostgreSQL) GetShortURL(ctx context.Context, longURL string) (string, error) {
+// GetShortURL returns the short URL for a given long URL and redirect status.
+func (p *PostgreSQL) GetShortURL(ctx context.Context, longURL string, redirectStatus int) (string, error) {
 	var shortURL string
-	if err := p.dbConn.GetContext(ctx, &shortURL, getShortURLQuery, longURL); err != nil {
+	if err := p.dbConn.GetContext(ctx, &shortURL, getShortURLQuery, longURL, redirectStatus); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", nil
 		}
@@ -52,48 +91,327 @@ func (p *PostgreSQL) GetShortURL(ctx context.Context, longURL string) (string, e
 	return shortURL, nil
 }
 
-// GetLongURL returns the long URL for a given short URL.
-func (p *PostgreSQL) GetLongURL(ctx context.Context, shortURL string) (string, error) {
+// GetURL returns the URL stored for a given short URL without recording a hit.
+func (p *PostgreSQL) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	var url URL
+	if err := p.dbConn.GetContext(ctx, &url, getURLQuery, shortURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return URL{}, nil
+		}
+		return URL{}, fmt.Errorf("could not get URL from database: %w", err)
+	}
+	return url, nil
+}
+
+// RecordHit increments the hits of a short URL, updates its last hit time and logs the hit.
+func (p *PostgreSQL) RecordHit(ctx context.Context, hit Hit) error {
 	tx, err := p.dbConn.BeginTxx(ctx, nil)
 	if err != nil {
-		return "", fmt.Errorf("could not begin transaction: %w", err)
+		return fmt.Errorf("could not begin transaction: %w", err)
 	}
-	defer tx.Rollback()
+	defer p.rollback(ctx, tx)
 
-	var longURL string
-	if err := tx.GetContext(ctx, &longURL, getLongURLQuery, shortURL); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", nil
-		}
-		return "", fmt.Errorf("could not get long URL from database: %w", err)
+	if _, err := tx.ExecContext(ctx, updateHitsAndLastHitAtQuery, hit.ShortURL); err != nil {
+		return fmt.Errorf("could not update hits and last_hit_at: %w", err)
 	}
 
-	if _, err := tx.ExecContext(ctx, updateHitsAndLastHitAtQuery, shortURL); err != nil {
-		return "", fmt.Errorf("could not update hits and last_hit_at: %w", err)
+	if _, err := tx.NamedExecContext(ctx, insertHitEventQuery, hit); err != nil {
+		return fmt.Errorf("could not insert hit event: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return "", fmt.Errorf("could not commit transaction: %w", err)
+		return fmt.Errorf("could not commit transaction: %w", err)
 	}
-	return longURL, nil
+	return nil
+}
+
+// RecordBotHit increments the hits of a short URL made by bots.
+func (p *PostgreSQL) RecordBotHit(ctx context.Context, shortURL string) error {
+	if _, err := p.dbConn.ExecContext(ctx, updateBotHitsQuery, shortURL); err != nil {
+		return fmt.Errorf("could not update bot hits: %w", err)
+	}
+	return nil
 }
 
 // SaveShortURL saves a short URL to the database.
-func (p *PostgreSQL) SaveShortURL(ctx context.Context, shortURL, longURL string) error {
-	if _, err := p.dbConn.ExecContext(ctx, saveShortURLQuery, shortURL, longURL); err != nil {
+func (p *PostgreSQL) SaveShortURL(ctx context.Context, url URL) error {
+	if _, err := p.dbConn.NamedExecContext(ctx, saveShortURLQuery, url); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("could not save short URL %s: %w", url.ShortURL, ErrShortURLTaken)
+		}
 		return fmt.Errorf("could not save short URL to database: %w", err)
 	}
 	return nil
 }
 
 // Get sats for a given short URL.
-func (p *PostgreSQL) GetStats(ctx context.Context, shortURL string) (int, error) {
-	var hits int
-	if err := p.dbConn.GetContext(ctx, &hits, geStatsQuery, shortURL); err != nil {
+func (p *PostgreSQL) GetStats(ctx context.Context, shortURL string) (Stats, error) {
+	var stats Stats
+	if err := p.dbConn.GetContext(ctx, &stats, geStatsQuery, shortURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Stats{}, nil
+		}
+		return Stats{}, fmt.Errorf("could not get hits from database: %w", err)
+	}
+	return stats, nil
+}
+
+// SaveRule saves a redirect rule to the database.
+func (p *PostgreSQL) SaveRule(ctx context.Context, rule Rule) (Rule, error) {
+	var saved Rule
+	if err := p.dbConn.GetContext(ctx, &saved, saveRuleQuery,
+		rule.ShortURL, rule.Kind, rule.Condition, rule.TargetURL, rule.Priority,
+	); err != nil {
+		return Rule{}, fmt.Errorf("could not save rule to database: %w", err)
+	}
+	return saved, nil
+}
+
+// ListRules returns the redirect rules of a short URL in evaluation order.
+func (p *PostgreSQL) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	var rules []Rule
+	if err := p.dbConn.SelectContext(ctx, &rules, listRulesQuery, shortURL); err != nil {
+		return nil, fmt.Errorf("could not list rules from database: %w", err)
+	}
+	return rules, nil
+}
+
+// DeleteRule deletes a redirect rule and reports whether it existed.
+func (p *PostgreSQL) DeleteRule(ctx context.Context, shortURL string, id int64) (bool, error) {
+	res, err := p.dbConn.ExecContext(ctx, deleteRuleQuery, shortURL, id)
+	if err != nil {
+		return false, fmt.Errorf("could not delete rule from database: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// ReplaceVariants replaces the variants of a short URL and whether visitors
+// stick to one. Variants are matched by target URL, so those kept keep their
+// ID and hits, and only the ones left out are deleted.
+func (p *PostgreSQL) ReplaceVariants(ctx context.Context, shortURL string, sticky bool, variants []Variant) ([]Variant, error) {
+	tx, err := p.dbConn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer p.rollback(ctx, tx)
+
+	targets := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		targets = append(targets, variant.TargetURL)
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteDroppedVariantsQuery, shortURL, pq.Array(targets)); err != nil {
+		return nil, fmt.Errorf("could not delete variants: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, updateStickyVariantsQuery, shortURL, sticky); err != nil {
+		return nil, fmt.Errorf("could not update sticky variants: %w", err)
+	}
+
+	saved := make([]Variant, 0, len(variants))
+	for _, variant := range variants {
+		var v Variant
+		if err := tx.GetContext(ctx, &v, saveVariantQuery, shortURL, variant.TargetURL, variant.Weight); err != nil {
+			return nil, fmt.Errorf("could not save variant: %w", err)
+		}
+		saved = append(saved, v)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return saved, nil
+}
+
+// ListVariants returns the variants of a short URL.
+func (p *PostgreSQL) ListVariants(ctx context.Context, shortURL string) ([]Variant, error) {
+	var variants []Variant
+	if err := p.dbConn.SelectContext(ctx, &variants, listVariantsQuery, shortURL); err != nil {
+		return nil, fmt.Errorf("could not list variants from database: %w", err)
+	}
+	return variants, nil
+}
+
+// RecordVariantHit increments the hits of a variant.
+func (p *PostgreSQL) RecordVariantHit(ctx context.Context, id int64) error {
+	if _, err := p.dbConn.ExecContext(ctx, updateVariantHitsQuery, id); err != nil {
+		return fmt.Errorf("could not update variant hits: %w", err)
+	}
+	return nil
+}
+
+// AddVisitor adds the hash of a visitor to the sketch of a short URL for the
+// given day. The first visitor of the day creates the sketch; later ones only
+// raise the register they fall in, in a single statement, so concurrent
+// visitors do not wait on each other to read and write the whole sketch.
+func (p *PostgreSQL) AddVisitor(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+	sketch := hll.New()
+	sketch.Add(hash)
+
+	data, err := sketch.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("could not encode sketch: %w", err)
+	}
+
+	offset, rank := hll.EncodedRegister(hash)
+
+	if _, err := p.dbConn.ExecContext(ctx, addVisitorQuery, shortURL, day, data, offset, int(rank)); err != nil {
+		return fmt.Errorf("could not add visitor to sketch: %w", err)
+	}
+	return nil
+}
+
+// ListVisitorSketches returns the daily visitor sketches of a short URL, oldest first.
+func (p *PostgreSQL) ListVisitorSketches(ctx context.Context, shortURL string) ([]VisitorSketch, error) {
+	var sketches []VisitorSketch
+	if err := p.dbConn.SelectContext(ctx, &sketches, listVisitorSketchesQuery, shortURL); err != nil {
+		return nil, fmt.Errorf("could not list visitor sketches from database: %w", err)
+	}
+	return sketches, nil
+}
+
+// TopSources returns the most common values of a dimension among the hits of a short URL.
+// Hits without a value for the dimension are left out.
+func (p *PostgreSQL) TopSources(ctx context.Context, query TopSourcesQuery) ([]SourceCount, error) {
+	if !dimensionColumns[query.Dimension] {
+		return nil, fmt.Errorf("unknown dimension %q", query.Dimension)
+	}
+
+	var sources []SourceCount
+	if err := p.dbConn.SelectContext(ctx, &sources, fmt.Sprintf(topSourcesQuery, query.Dimension),
+		query.ShortURL, query.From, query.To, query.Limit, query.Offset,
+	); err != nil {
+		return nil, fmt.Errorf("could not get top sources from database: %w", err)
+	}
+	return sources, nil
+}
+
+// HitSeries returns the number of hits of a short URL per interval, leaving out intervals without hits.
+func (p *PostgreSQL) HitSeries(ctx context.Context, query HitSeriesQuery) ([]Bucket, error) {
+	var buckets []Bucket
+	if err := p.dbConn.SelectContext(ctx, &buckets, hitSeriesQuery,
+		query.ShortURL, query.From, query.To, query.Interval, query.Location,
+	); err != nil {
+		return nil, fmt.Errorf("could not get hit series from database: %w", err)
+	}
+	return buckets, nil
+}
+
+// DeleteURL deletes a short URL along with its rules, variants and hit history.
+// It reports whether the short URL existed.
+func (p *PostgreSQL) DeleteURL(ctx context.Context, shortURL string) (bool, error) {
+	res, err := p.dbConn.ExecContext(ctx, deleteURLQuery, shortURL)
+	if err != nil {
+		return false, fmt.Errorf("could not delete URL from database: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// SaveAPIKey stores an API key and returns it with its ID and creation time.
+func (p *PostgreSQL) SaveAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	var saved APIKey
+	if err := p.dbConn.GetContext(ctx, &saved, saveAPIKeyQuery, key.Name, key.KeyHash); err != nil {
+		return APIKey{}, fmt.Errorf("could not save API key to database: %w", err)
+	}
+	return saved, nil
+}
+
+// GetAPIKeyByHash returns the API key with the given hash, revoked or not.
+func (p *PostgreSQL) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (APIKey, error) {
+	var key APIKey
+	if err := p.dbConn.GetContext(ctx, &key, getAPIKeyByHashQuery, keyHash); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, nil
+			return APIKey{}, nil
 		}
-		return 0, fmt.Errorf("could not get hits from database: %w", err)
+		return APIKey{}, fmt.Errorf("could not get API key from database: %w", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey revokes an API key, keeping the time of an earlier revocation.
+// It reports whether the API key exists.
+func (p *PostgreSQL) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	res, err := p.dbConn.ExecContext(ctx, revokeAPIKeyQuery, id)
+	if err != nil {
+		return false, fmt.Errorf("could not revoke API key in database: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// ExportURLs calls fn with each short URL owned by the API key ownerKeyID,
+// or with every short URL when ownerKeyID is zero, oldest first. The rows
+// are fetched through a cursor in batches, so memory use does not grow with
+// the number of short URLs. An error returned by fn stops the export.
+func (p *PostgreSQL) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	tx, err := p.dbConn.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer p.rollback(ctx, tx)
+
+	// DECLARE does not accept bind parameters, so the ID is formatted into
+	// the query. Being an integer, it cannot inject SQL.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(declareExportCursorQuery, ownerKeyID)); err != nil {
+		return fmt.Errorf("could not declare export cursor: %w", err)
+	}
+
+	for {
+		var batch []ExportedURL
+		if err := tx.SelectContext(ctx, &batch, fetchExportCursorQuery); err != nil {
+			return fmt.Errorf("could not fetch exported URLs: %w", err)
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, url := range batch {
+			if err := fn(url); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ImportURL saves a short URL brought over from another shortener, with its
+// hits and creation time. It reports false, saving nothing, when the short
+// URL is already taken.
+func (p *PostgreSQL) ImportURL(ctx context.Context, url URL) (bool, error) {
+	res, err := p.dbConn.NamedExecContext(ctx, importURLQuery, url)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return false, fmt.Errorf("could not import URL %s: %w", url.ShortURL, ErrOwnerNotFound)
+		}
+		return false, fmt.Errorf("could not import URL to database: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not get affected rows: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// rollback rolls tx back unless it has been committed, logging failures.
+func (p *PostgreSQL) rollback(ctx context.Context, tx *sqlx.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		requestid.Logger(ctx, p.logger).Error("could not roll back transaction", zap.Error(err))
 	}
-	return hits, nil
 }
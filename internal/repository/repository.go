@@ -1,11 +1,157 @@
 package repository
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShortURLTaken is returned when saving a short URL that is already stored.
+var ErrShortURLTaken = errors.New("short url is already taken")
+
+// ErrOwnerNotFound is returned when saving a short URL owned by an API key that does not exist.
+var ErrOwnerNotFound = errors.New("owner api key not found")
+
+// URL represents a short URL stored in the repository.
+type URL struct {
+	ShortURL       string     `db:"short_url"`
+	LongURL        string     `db:"long_url"`
+	RedirectStatus int        `db:"redirect_status"`
+	PasswordHash   string     `db:"password_hash"`
+	NotBefore      *time.Time `db:"not_before"`
+	NotAfter       *time.Time `db:"not_after"`
+	StickyVariants bool       `db:"sticky_variants"`
+	Hits           int        `db:"hits"`
+	CreatedAt      time.Time  `db:"created_at"`
+	OwnerKeyID     *int64     `db:"owner_key_id"`
+}
+
+// Rule is a redirect rule sending matching requests for a short URL to another target.
+type Rule struct {
+	ID        int64     `db:"id"`
+	ShortURL  string    `db:"short_url"`
+	Kind      string    `db:"kind"`
+	Condition string    `db:"condition"`
+	TargetURL string    `db:"target_url"`
+	Priority  int       `db:"priority"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Variant is a weighted alternative destination of a short URL.
+type Variant struct {
+	ID        int64     `db:"id"`
+	ShortURL  string    `db:"short_url"`
+	TargetURL string    `db:"target_url"`
+	Weight    int       `db:"weight"`
+	Hits      int       `db:"hits"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Hit is a request redirected by a short URL, made by a person rather than a bot.
+type Hit struct {
+	ShortURL        string    `db:"short_url"`
+	OccurredAt      time.Time `db:"occurred_at"`
+	ReferrerDomain  string    `db:"referrer_domain"`
+	UTMSource       string    `db:"utm_source"`
+	UserAgentFamily string    `db:"ua_family"`
+}
+
+// Dimension is an attribute of hits that they can be grouped by.
+type Dimension string
+
+// Dimensions of hits.
+const (
+	DimensionReferrer        Dimension = "referrer_domain"
+	DimensionUTMSource       Dimension = "utm_source"
+	DimensionUserAgentFamily Dimension = "ua_family"
+)
+
+// TopSourcesQuery selects the most common values of a dimension among the
+// hits of a short URL that occurred in [From, To).
+type TopSourcesQuery struct {
+	ShortURL  string
+	Dimension Dimension
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// SourceCount is the number of hits having a value of a dimension.
+type SourceCount struct {
+	Value string `db:"value"`
+	Hits  int    `db:"hits"`
+}
+
+// HitSeriesQuery selects the hits of a short URL that occurred in [From, To),
+// counted by Interval, a PostgreSQL date_trunc field, in the Location time zone.
+type HitSeriesQuery struct {
+	ShortURL string
+	From     time.Time
+	To       time.Time
+	Interval string
+	Location string
+}
+
+// Bucket is the number of hits in an interval. Start is the wall clock time
+// the interval starts at in the time zone of the query, expressed in UTC.
+type Bucket struct {
+	Start time.Time `db:"start"`
+	Hits  int       `db:"hits"`
+}
+
+// Stats holds the hit counters of a short URL.
+type Stats struct {
+	Hits    int `db:"hits"`
+	BotHits int `db:"bot_hits"`
+}
+
+// VisitorSketch is the HyperLogLog sketch of the visitors of a short URL on a day.
+type VisitorSketch struct {
+	Day    time.Time `db:"day"`
+	Sketch []byte    `db:"sketch"`
+}
+
+// APIKey is a credential of an API client. Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	ID        int64      `db:"id"`
+	Name      string     `db:"name"`
+	KeyHash   []byte     `db:"key_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// ExportedURL is a short URL with its hit counters, as listed by an export.
+type ExportedURL struct {
+	ShortURL  string     `db:"short_url"`
+	LongURL   string     `db:"long_url"`
+	CreatedAt time.Time  `db:"created_at"`
+	Hits      int        `db:"hits"`
+	LastHitAt *time.Time `db:"last_hit_at"`
+}
 
 // Repository is an interface that defines the methods that a repository should implement.
 type Repository interface {
-	GetShortURL(ctx context.Context, longURL string) (string, error)
-	GetLongURL(ctx context.Context, shortURL string) (string, error)
-	GetStats(ctx context.Context, shortURL string) (int, error)
-	SaveShortURL(ctx context.Context, longURL, shortURL string) error
+	GetShortURL(ctx context.Context, longURL string, redirectStatus int) (string, error)
+	GetURL(ctx context.Context, shortURL string) (URL, error)
+	RecordHit(ctx context.Context, hit Hit) error
+	RecordBotHit(ctx context.Context, shortURL string) error
+	GetStats(ctx context.Context, shortURL string) (Stats, error)
+	SaveShortURL(ctx context.Context, url URL) error
+	SaveRule(ctx context.Context, rule Rule) (Rule, error)
+	ListRules(ctx context.Context, shortURL string) ([]Rule, error)
+	DeleteRule(ctx context.Context, shortURL string, id int64) (bool, error)
+	ReplaceVariants(ctx context.Context, shortURL string, sticky bool, variants []Variant) ([]Variant, error)
+	ListVariants(ctx context.Context, shortURL string) ([]Variant, error)
+	RecordVariantHit(ctx context.Context, id int64) error
+	AddVisitor(ctx context.Context, shortURL string, day time.Time, hash uint64) error
+	ListVisitorSketches(ctx context.Context, shortURL string) ([]VisitorSketch, error)
+	TopSources(ctx context.Context, query TopSourcesQuery) ([]SourceCount, error)
+	HitSeries(ctx context.Context, query HitSeriesQuery) ([]Bucket, error)
+	DeleteURL(ctx context.Context, shortURL string) (bool, error)
+	SaveAPIKey(ctx context.Context, key APIKey) (APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash []byte) (APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (bool, error)
+	ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error
+	ImportURL(ctx context.Context, url URL) (bool, error)
 }
@@ -1,28 +1,124 @@
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 var _ Repository = (*Mock)(nil)
 
 type Mock struct {
-	GetShortURLFunc  func(ctx context.Context, longURL string) (string, error)
-	GetLongURLFunc   func(ctx context.Context, shortURL string) (string, error)
-	GetStatsFunc     func(ctx context.Context, shortURL string) (int, error)
-	SaveShortURLFunc func(ctx context.Context, longURL, shortURL string) error
+	GetShortURLFunc  func(ctx context.Context, longURL string, redirectStatus int) (string, error)
+	GetURLFunc       func(ctx context.Context, shortURL string) (URL, error)
+	RecordHitFunc    func(ctx context.Context, hit Hit) error
+	RecordBotHitFunc func(ctx context.Context, shortURL string) error
+	GetStatsFunc     func(ctx context.Context, shortURL string) (Stats, error)
+	SaveShortURLFunc func(ctx context.Context, url URL) error
+	SaveRuleFunc     func(ctx context.Context, rule Rule) (Rule, error)
+	ListRulesFunc    func(ctx context.Context, shortURL string) ([]Rule, error)
+	DeleteRuleFunc   func(ctx context.Context, shortURL string, id int64) (bool, error)
+
+	ReplaceVariantsFunc  func(ctx context.Context, shortURL string, sticky bool, variants []Variant) ([]Variant, error)
+	ListVariantsFunc     func(ctx context.Context, shortURL string) ([]Variant, error)
+	RecordVariantHitFunc func(ctx context.Context, id int64) error
+
+	AddVisitorFunc          func(ctx context.Context, shortURL string, day time.Time, hash uint64) error
+	ListVisitorSketchesFunc func(ctx context.Context, shortURL string) ([]VisitorSketch, error)
+	TopSourcesFunc          func(ctx context.Context, query TopSourcesQuery) ([]SourceCount, error)
+	HitSeriesFunc           func(ctx context.Context, query HitSeriesQuery) ([]Bucket, error)
+
+	DeleteURLFunc       func(ctx context.Context, shortURL string) (bool, error)
+	SaveAPIKeyFunc      func(ctx context.Context, key APIKey) (APIKey, error)
+	GetAPIKeyByHashFunc func(ctx context.Context, keyHash []byte) (APIKey, error)
+	RevokeAPIKeyFunc    func(ctx context.Context, id int64) (bool, error)
+	ExportURLsFunc      func(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error
+	ImportURLFunc       func(ctx context.Context, url URL) (bool, error)
+}
+
+func (m *Mock) GetShortURL(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+	return m.GetShortURLFunc(ctx, longURL, redirectStatus)
 }
 
-func (m *Mock) GetShortURL(ctx context.Context, longURL string) (string, error) {
-	return m.GetShortURLFunc(ctx, longURL)
+func (m *Mock) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	return m.GetURLFunc(ctx, shortURL)
 }
 
-func (m *Mock) GetLongURL(ctx context.Context, shortURL string) (string, error) {
-	return m.GetLongURLFunc(ctx, shortURL)
+func (m *Mock) RecordHit(ctx context.Context, hit Hit) error {
+	return m.RecordHitFunc(ctx, hit)
 }
 
-func (m *Mock) GetStats(ctx context.Context, shortURL string) (int, error) {
+func (m *Mock) RecordBotHit(ctx context.Context, shortURL string) error {
+	return m.RecordBotHitFunc(ctx, shortURL)
+}
+
+func (m *Mock) GetStats(ctx context.Context, shortURL string) (Stats, error) {
 	return m.GetStatsFunc(ctx, shortURL)
 }
 
-func (m *Mock) SaveShortURL(ctx context.Context, longURL, shortURL string) error {
-	return m.SaveShortURLFunc(ctx, longURL, shortURL)
+func (m *Mock) SaveShortURL(ctx context.Context, url URL) error {
+	return m.SaveShortURLFunc(ctx, url)
+}
+
+func (m *Mock) SaveRule(ctx context.Context, rule Rule) (Rule, error) {
+	return m.SaveRuleFunc(ctx, rule)
+}
+
+func (m *Mock) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	return m.ListRulesFunc(ctx, shortURL)
+}
+
+func (m *Mock) DeleteRule(ctx context.Context, shortURL string, id int64) (bool, error) {
+	return m.DeleteRuleFunc(ctx, shortURL, id)
+}
+
+func (m *Mock) ReplaceVariants(ctx context.Context, shortURL string, sticky bool, variants []Variant) ([]Variant, error) {
+	return m.ReplaceVariantsFunc(ctx, shortURL, sticky, variants)
+}
+
+func (m *Mock) ListVariants(ctx context.Context, shortURL string) ([]Variant, error) {
+	return m.ListVariantsFunc(ctx, shortURL)
+}
+
+func (m *Mock) RecordVariantHit(ctx context.Context, id int64) error {
+	return m.RecordVariantHitFunc(ctx, id)
+}
+
+func (m *Mock) AddVisitor(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+	return m.AddVisitorFunc(ctx, shortURL, day, hash)
+}
+
+func (m *Mock) ListVisitorSketches(ctx context.Context, shortURL string) ([]VisitorSketch, error) {
+	return m.ListVisitorSketchesFunc(ctx, shortURL)
+}
+
+func (m *Mock) TopSources(ctx context.Context, query TopSourcesQuery) ([]SourceCount, error) {
+	return m.TopSourcesFunc(ctx, query)
+}
+
+func (m *Mock) HitSeries(ctx context.Context, query HitSeriesQuery) ([]Bucket, error) {
+	return m.HitSeriesFunc(ctx, query)
+}
+
+func (m *Mock) DeleteURL(ctx context.Context, shortURL string) (bool, error) {
+	return m.DeleteURLFunc(ctx, shortURL)
+}
+
+func (m *Mock) SaveAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	return m.SaveAPIKeyFunc(ctx, key)
+}
+
+func (m *Mock) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (APIKey, error) {
+	return m.GetAPIKeyByHashFunc(ctx, keyHash)
+}
+
+func (m *Mock) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	return m.RevokeAPIKeyFunc(ctx, id)
+}
+
+func (m *Mock) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	return m.ExportURLsFunc(ctx, ownerKeyID, fn)
+}
+
+func (m *Mock) ImportURL(ctx context.Context, url URL) (bool, error) {
+	return m.ImportURLFunc(ctx, url)
 }
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ Repository = (*Traced)(nil)
+
+// tracerName is the instrumentation name of the repository spans.
+const tracerName = "github.com/alesr/urltinyizer/internal/repository"
+
+// Traced is a repository recording a span for each operation of another.
+type Traced struct {
+	repo   Repository
+	tracer trace.Tracer
+}
+
+// NewTraced wraps repo with spans from the global tracer provider.
+func NewTraced(repo Repository) *Traced {
+	return &Traced{repo: repo, tracer: otel.Tracer(tracerName)}
+}
+
+func (t *Traced) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "repository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(method)),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *Traced) GetShortURL(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+	ctx, span := t.start(ctx, "GetShortURL")
+	result, err := t.repo.GetShortURL(ctx, longURL, redirectStatus)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	ctx, span := t.start(ctx, "GetURL")
+	result, err := t.repo.GetURL(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) RecordHit(ctx context.Context, hit Hit) error {
+	ctx, span := t.start(ctx, "RecordHit")
+	err := t.repo.RecordHit(ctx, hit)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) RecordBotHit(ctx context.Context, shortURL string) error {
+	ctx, span := t.start(ctx, "RecordBotHit")
+	err := t.repo.RecordBotHit(ctx, shortURL)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) GetStats(ctx context.Context, shortURL string) (Stats, error) {
+	ctx, span := t.start(ctx, "GetStats")
+	result, err := t.repo.GetStats(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) SaveShortURL(ctx context.Context, url URL) error {
+	ctx, span := t.start(ctx, "SaveShortURL")
+	err := t.repo.SaveShortURL(ctx, url)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) SaveRule(ctx context.Context, rule Rule) (Rule, error) {
+	ctx, span := t.start(ctx, "SaveRule")
+	result, err := t.repo.SaveRule(ctx, rule)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	ctx, span := t.start(ctx, "ListRules")
+	result, err := t.repo.ListRules(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) DeleteRule(ctx context.Context, shortURL string, id int64) (bool, error) {
+	ctx, span := t.start(ctx, "DeleteRule")
+	result, err := t.repo.DeleteRule(ctx, shortURL, id)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) ReplaceVariants(ctx context.Context, shortURL string, sticky bool, variants []Variant) ([]Variant, error) {
+	ctx, span := t.start(ctx, "ReplaceVariants")
+	result, err := t.repo.ReplaceVariants(ctx, shortURL, sticky, variants)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) ListVariants(ctx context.Context, shortURL string) ([]Variant, error) {
+	ctx, span := t.start(ctx, "ListVariants")
+	result, err := t.repo.ListVariants(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) RecordVariantHit(ctx context.Context, id int64) error {
+	ctx, span := t.start(ctx, "RecordVariantHit")
+	err := t.repo.RecordVariantHit(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) AddVisitor(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+	ctx, span := t.start(ctx, "AddVisitor")
+	err := t.repo.AddVisitor(ctx, shortURL, day, hash)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) ListVisitorSketches(ctx context.Context, shortURL string) ([]VisitorSketch, error) {
+	ctx, span := t.start(ctx, "ListVisitorSketches")
+	result, err := t.repo.ListVisitorSketches(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) TopSources(ctx context.Context, query TopSourcesQuery) ([]SourceCount, error) {
+	ctx, span := t.start(ctx, "TopSources")
+	result, err := t.repo.TopSources(ctx, query)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) HitSeries(ctx context.Context, query HitSeriesQuery) ([]Bucket, error) {
+	ctx, span := t.start(ctx, "HitSeries")
+	result, err := t.repo.HitSeries(ctx, query)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) DeleteURL(ctx context.Context, shortURL string) (bool, error) {
+	ctx, span := t.start(ctx, "DeleteURL")
+	result, err := t.repo.DeleteURL(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) SaveAPIKey(ctx context.Context, key APIKey) (APIKey, error) {
+	ctx, span := t.start(ctx, "SaveAPIKey")
+	result, err := t.repo.SaveAPIKey(ctx, key)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (APIKey, error) {
+	ctx, span := t.start(ctx, "GetAPIKeyByHash")
+	result, err := t.repo.GetAPIKeyByHash(ctx, keyHash)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	ctx, span := t.start(ctx, "RevokeAPIKey")
+	result, err := t.repo.RevokeAPIKey(ctx, id)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	ctx, span := t.start(ctx, "ExportURLs")
+	err := t.repo.ExportURLs(ctx, ownerKeyID, fn)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) ImportURL(ctx context.Context, url URL) (bool, error) {
+	ctx, span := t.start(ctx, "ImportURL")
+	result, err := t.repo.ImportURL(ctx, url)
+	endSpan(span, err)
+	return result, err
+}
@@ -0,0 +1,63 @@
+// Package requestid carries the ID of the request being served through
+// contexts so log lines can be correlated.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+// Header is the HTTP header a request ID is read from and echoed in.
+const Header = "X-Request-ID"
+
+// maxLength is the longest request ID accepted from clients.
+const maxLength = 128
+
+type contextKey struct{}
+
+// New returns a random request ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("requestid: could not read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid reports whether id can be propagated from a client. Only printable
+// ASCII without spaces is accepted so IDs are safe to log and echo.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or an empty string.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Logger returns logger with the request ID carried by ctx as a field.
+// It returns logger unchanged when ctx carries no request ID.
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id := FromContext(ctx); id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
@@ -0,0 +1,55 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestValid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		id       string
+		expected bool
+	}{
+		{name: "generated", id: New(), expected: true},
+		{name: "uuid", id: "3f2c8f9e-7a1b-4c2d-9e8f-0a1b2c3d4e5f", expected: true},
+		{name: "empty", id: "", expected: false},
+		{name: "space", id: "abc def", expected: false},
+		{name: "newline", id: "abc\ndef", expected: false},
+		{name: "non ascii", id: "ação", expected: false},
+		{name: "too long", id: strings.Repeat("a", maxLength+1), expected: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Valid(tc.id))
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	Logger(context.Background(), logger).Info("without id")
+	Logger(NewContext(context.Background(), "req-1"), logger).Info("with id")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+
+	assert.Empty(t, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "req-1"}, entries[1].ContextMap())
+}
@@ -0,0 +1,213 @@
+// Package schedule parses and evaluates weekly time windows such as
+// "mon-fri 09:00-17:00 Europe/Lisbon".
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+var dayNames = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// locations caches loaded time zones, since loading one reads the zone database.
+var locations sync.Map
+
+// Schedule is a weekly time window in a time zone. The window opens at Start
+// and closes at End, in minutes since midnight, on each of Days. When End is
+// not after Start the window runs overnight into the following day.
+type Schedule struct {
+	Days     [7]bool
+	Start    int
+	End      int
+	Location *time.Location
+}
+
+// Parse parses a schedule of the form "<days> [<HH:MM>-<HH:MM>] [<time zone>]".
+//
+// Days are comma-separated names (mon, tue, ...) or ranges (mon-fri), or one
+// of "*", "weekdays" and "weekends". Times are given as HH:MM or as whole
+// hours, as in 9-17. The time range defaults to the whole day and the time
+// zone, an IANA name, to UTC.
+func Parse(s string) (Schedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q", s)
+	}
+
+	sch := Schedule{End: minutesPerDay, Location: time.UTC}
+
+	days, err := parseDays(strings.ToLower(fields[0]))
+	if err != nil {
+		return Schedule{}, err
+	}
+	sch.Days = days
+
+	rest := fields[1:]
+	if len(rest) > 0 && rest[0][0] >= '0' && rest[0][0] <= '9' {
+		if sch.Start, sch.End, err = parseTimeRange(rest[0]); err != nil {
+			return Schedule{}, err
+		}
+		rest = rest[1:]
+	}
+
+	if len(rest) > 0 {
+		if sch.Location, err = loadLocation(rest[0]); err != nil {
+			return Schedule{}, err
+		}
+		rest = rest[1:]
+	}
+
+	if len(rest) > 0 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q", s)
+	}
+	return sch, nil
+}
+
+// Contains reports whether t falls within the schedule.
+func (s Schedule) Contains(t time.Time) bool {
+	t = t.In(s.Location)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+
+	if s.Start < s.End {
+		return s.Days[day] && minute >= s.Start && minute < s.End
+	}
+
+	// Overnight window: either the part before midnight on a scheduled day,
+	// or the part after midnight following a scheduled day.
+	yesterday := (day + 6) % 7
+	return (s.Days[day] && minute >= s.Start) || (s.Days[yesterday] && minute < s.End)
+}
+
+// String returns the schedule in canonical form.
+func (s Schedule) String() string {
+	var days []string
+	for i, ok := range s.Days {
+		if ok {
+			days = append(days, dayNames[i])
+		}
+	}
+	return fmt.Sprintf("%s %s-%s %s", strings.Join(days, ","), formatMinute(s.Start), formatMinute(s.End), s.Location)
+}
+
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+
+	switch s {
+	case "*":
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	case "weekdays":
+		return [7]bool{false, true, true, true, true, true, false}, nil
+	case "weekends":
+		return [7]bool{true, false, false, false, false, false, true}, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+
+		first, ok := weekdays[from]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q", from)
+		}
+
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return days, fmt.Errorf("invalid day %q", to)
+			}
+		}
+
+		// Ranges may wrap around the end of the week, as in fri-mon.
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeRange(s string) (int, int, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time range %q", s)
+	}
+
+	start, err := parseMinute(from)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := parseMinute(to)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if start == minutesPerDay || start == end {
+		return 0, 0, fmt.Errorf("invalid time range %q", s)
+	}
+	return start, end, nil
+}
+
+// parseMinute parses HH:MM or a whole hour into minutes since midnight,
+// accepting 24:00 as the end of the day.
+func parseMinute(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		mm = "00"
+	}
+
+	if len(hh) < 1 || len(hh) > 2 || len(mm) != 2 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	hour, err := strconv.Atoi(hh)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	minute, err := strconv.Atoi(mm)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	total := hour*60 + minute
+	if hour < 0 || minute < 0 || minute > 59 || total > minutesPerDay {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return total, nil
+}
+
+func formatMinute(m int) string {
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+
+	locations.Store(name, loc)
+	return loc, nil
+}
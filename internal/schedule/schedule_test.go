@@ -0,0 +1,87 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		given    string
+		expected string
+		wantErr  bool
+	}{
+		{given: "mon-fri 09:00-17:00 Europe/Lisbon", expected: "mon,tue,wed,thu,fri 09:00-17:00 Europe/Lisbon"},
+		{given: "weekdays 9-17 Europe/Lisbon", expected: "mon,tue,wed,thu,fri 09:00-17:00 Europe/Lisbon"},
+		{given: "weekends", expected: "sun,sat 00:00-24:00 UTC"},
+		{given: "fri-mon 22:00-02:00", expected: "sun,mon,fri,sat 22:00-02:00 UTC"},
+		{given: "SAT,sun America/Sao_Paulo", expected: "sun,sat 00:00-24:00 America/Sao_Paulo"},
+		{given: "", wantErr: true},
+		{given: "someday", wantErr: true},
+		{given: "mon 9:0-17:00", wantErr: true},
+		{given: "mon 09:00-09:00", wantErr: true},
+		{given: "mon 09:00-25:00", wantErr: true},
+		{given: "mon 09:00-17:00 Mars/Olympus", wantErr: true},
+		{given: "mon 09:00-17:00 UTC extra", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.given, func(t *testing.T) {
+			t.Parallel()
+
+			observed, err := Parse(tc.given)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, observed.String())
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	t.Parallel()
+
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	require.NoError(t, err)
+
+	office, err := Parse("weekdays 09:00-17:00 Europe/Lisbon")
+	require.NoError(t, err)
+
+	overnight, err := Parse("fri 22:00-02:00 UTC")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		schedule Schedule
+		given    time.Time
+		expected bool
+	}{
+		// 2023-07-03 is a Monday; Lisbon is on UTC+1 in summer.
+		{name: "office hours", schedule: office, given: time.Date(2023, 7, 3, 9, 0, 0, 0, lisbon), expected: true},
+		{name: "office hours in utc", schedule: office, given: time.Date(2023, 7, 3, 8, 30, 0, 0, time.UTC), expected: true},
+		{name: "before opening in utc", schedule: office, given: time.Date(2023, 7, 3, 7, 59, 0, 0, time.UTC), expected: false},
+		{name: "closing time", schedule: office, given: time.Date(2023, 7, 3, 17, 0, 0, 0, lisbon), expected: false},
+		{name: "weekend", schedule: office, given: time.Date(2023, 7, 8, 12, 0, 0, 0, lisbon), expected: false},
+		{name: "overnight before midnight", schedule: overnight, given: time.Date(2023, 7, 7, 23, 0, 0, 0, time.UTC), expected: true},
+		{name: "overnight after midnight", schedule: overnight, given: time.Date(2023, 7, 8, 1, 59, 0, 0, time.UTC), expected: true},
+		{name: "overnight wrong day", schedule: overnight, given: time.Date(2023, 7, 7, 1, 0, 0, 0, time.UTC), expected: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.schedule.Contains(tc.given))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+)
+
+const (
+	// apiKeyPrefix makes API keys recognizable, e.g. by secret scanners.
+	apiKeyPrefix = "utk_"
+
+	apiKeyLength     = 32
+	maxAPIKeyNameLen = 100
+)
+
+func (s *ServiceDefault) CreateAPIKey(ctx context.Context, name string) (APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return APIKey{}, fmt.Errorf("name must have between 1 and %d characters: %w", maxAPIKeyNameLen, ErrInvalidAPIKeyName)
+	}
+
+	b := make([]byte, apiKeyLength)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, fmt.Errorf("could not generate api key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	saved, err := s.repo.SaveAPIKey(ctx, repository.APIKey{Name: name, KeyHash: hashAPIKey(key)})
+	if err != nil {
+		return APIKey{}, fmt.Errorf("could not save api key: %w", err)
+	}
+
+	apiKey := newAPIKey(saved)
+	apiKey.Key = key
+	return apiKey, nil
+}
+
+func (s *ServiceDefault) RevokeAPIKey(ctx context.Context, id int64) error {
+	revoked, err := s.repo.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not revoke api key: %w", err)
+	}
+
+	if !revoked {
+		return fmt.Errorf("could not find api key %d: %w", id, ErrAPIKeyNotFound)
+	}
+	return nil
+}
+
+func (s *ServiceDefault) AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	stored, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		return APIKey{}, fmt.Errorf("could not get api key: %w", err)
+	}
+
+	if stored.ID == 0 || stored.RevokedAt != nil {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	return newAPIKey(stored), nil
+}
+
+// hashAPIKey returns the hash an API key is stored and looked up by. Keys
+// are random, so a fast unsalted hash is enough.
+func hashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+func newAPIKey(key repository.APIKey) APIKey {
+	return APIKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
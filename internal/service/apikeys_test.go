@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newAPIKeysRepoMock stores API keys in memory.
+func newAPIKeysRepoMock() *repository.Mock {
+	var keys []repository.APIKey
+
+	return &repository.Mock{
+		SaveAPIKeyFunc: func(ctx context.Context, key repository.APIKey) (repository.APIKey, error) {
+			key.ID = int64(len(keys) + 1)
+			key.CreatedAt = time.Now()
+			keys = append(keys, key)
+			return key, nil
+		},
+		GetAPIKeyByHashFunc: func(ctx context.Context, keyHash []byte) (repository.APIKey, error) {
+			for _, key := range keys {
+				if bytes.Equal(key.KeyHash, keyHash) {
+					return key, nil
+				}
+			}
+			return repository.APIKey{}, nil
+		},
+		RevokeAPIKeyFunc: func(ctx context.Context, id int64) (bool, error) {
+			for i := range keys {
+				if keys[i].ID == id {
+					now := time.Now()
+					keys[i].RevokedAt = &now
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("create, authenticate and revoke", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newAPIKeysRepoMock())
+
+		created, err := svc.CreateAPIKey(context.Background(), " ci ")
+		require.NoError(t, err)
+
+		require.Equal(t, "ci", created.Name)
+		require.True(t, strings.HasPrefix(created.Key, apiKeyPrefix))
+
+		authenticated, err := svc.AuthenticateAPIKey(context.Background(), created.Key)
+		require.NoError(t, err)
+
+		require.Equal(t, created.ID, authenticated.ID)
+		require.Empty(t, authenticated.Key)
+
+		require.NoError(t, svc.RevokeAPIKey(context.Background(), created.ID))
+
+		_, err = svc.AuthenticateAPIKey(context.Background(), created.Key)
+		require.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newAPIKeysRepoMock())
+
+		_, err := svc.AuthenticateAPIKey(context.Background(), apiKeyPrefix+"unknown")
+		require.ErrorIs(t, err, ErrInvalidAPIKey)
+
+		_, err = svc.AuthenticateAPIKey(context.Background(), "not-a-key")
+		require.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newAPIKeysRepoMock())
+
+		_, err := svc.CreateAPIKey(context.Background(), "  ")
+		require.ErrorIs(t, err, ErrInvalidAPIKeyName)
+	})
+
+	t.Run("revoke unknown key", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newAPIKeysRepoMock())
+
+		err := svc.RevokeAPIKey(context.Background(), 42)
+		require.ErrorIs(t, err, ErrAPIKeyNotFound)
+	})
+}
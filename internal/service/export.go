@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+)
+
+// ExportURLs calls fn with each short URL created with the API key
+// ownerKeyID, or with every short URL when ownerKeyID is zero, oldest first.
+// Short URLs are streamed from the repository, and an error returned by fn
+// stops the export and is returned as is.
+func (s *ServiceDefault) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	var fnErr error
+	err := s.repo.ExportURLs(ctx, ownerKeyID, func(url repository.ExportedURL) error {
+		fnErr = fn(ExportedURL{
+			ShortURL:  url.ShortURL,
+			LongURL:   url.LongURL,
+			CreatedAt: url.CreatedAt,
+			Hits:      url.Hits,
+			LastHitAt: url.LastHitAt,
+		})
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not export urls: %w", err)
+	}
+	return nil
+}
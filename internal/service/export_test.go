@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestExportURLs(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	lastHitAt := createdAt.Add(time.Hour)
+
+	repoMock := &repository.Mock{
+		ExportURLsFunc: func(ctx context.Context, ownerKeyID int64, fn func(repository.ExportedURL) error) error {
+			require.Equal(t, int64(3), ownerKeyID)
+
+			for _, url := range []repository.ExportedURL{
+				{ShortURL: "http://bar/a", LongURL: "https://www.foo.com/a", CreatedAt: createdAt, Hits: 2, LastHitAt: &lastHitAt},
+				{ShortURL: "http://bar/b", LongURL: "https://www.foo.com/b", CreatedAt: createdAt},
+			} {
+				if err := fn(url); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+	t.Run("export urls", func(t *testing.T) {
+		t.Parallel()
+
+		var observed []ExportedURL
+		err := svc.ExportURLs(context.Background(), 3, func(url ExportedURL) error {
+			observed = append(observed, url)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []ExportedURL{
+			{ShortURL: "http://bar/a", LongURL: "https://www.foo.com/a", CreatedAt: createdAt, Hits: 2, LastHitAt: &lastHitAt},
+			{ShortURL: "http://bar/b", LongURL: "https://www.foo.com/b", CreatedAt: createdAt},
+		}, observed)
+	})
+
+	t.Run("error stops the export", func(t *testing.T) {
+		t.Parallel()
+
+		errWrite := errors.New("write failed")
+
+		var calls int
+		err := svc.ExportURLs(context.Background(), 3, func(url ExportedURL) error {
+			calls++
+			return errWrite
+		})
+		require.Equal(t, errWrite, err)
+		require.Equal(t, 1, calls)
+	})
+}
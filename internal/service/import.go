@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+)
+
+// maxImportCodeLength is the length of the longest code an import may bring over.
+const maxImportCodeLength = 64
+
+// ImportURLs saves links exported from another URL shortener under their
+// original codes, so each code keeps working as a short URL of the app host,
+// along with its hits and creation time. Records whose code is taken by
+// another long URL, or repeated in the import, are reported as conflicts and
+// records that cannot be saved as invalid. Importing the same records again
+// only reports them as unchanged. The links are owned by the API key
+// ownerKeyID, or by no key when it is zero.
+func (s *ServiceDefault) ImportURLs(ctx context.Context, records []ImportRecord, ownerKeyID int64, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun}
+
+	// seen maps the codes of the import to the position they first appear at.
+	seen := make(map[string]int, len(records))
+
+	for _, record := range records {
+		issue := ImportIssue{Position: record.Position, Code: record.Code, LongURL: record.LongURL}
+
+		if reason := validateImportRecord(record); reason != "" {
+			issue.Reason = reason
+			report.Invalid = append(report.Invalid, issue)
+			continue
+		}
+
+		if position, ok := seen[record.Code]; ok {
+			issue.Reason = fmt.Sprintf("code is repeated from record %d", position)
+			report.Conflicts = append(report.Conflicts, issue)
+			continue
+		}
+		seen[record.Code] = record.Position
+
+		// Such a code would be taken from the long URL whose short URL is
+		// generated with it, which then could not be shortened.
+		if generatedCode(record.Code) {
+			issue.Reason = "code has the form of generated codes"
+			report.Conflicts = append(report.Conflicts, issue)
+			continue
+		}
+
+		shortURL := s.appHost + record.Code
+
+		existing, err := s.repo.GetURL(ctx, shortURL)
+		if err != nil {
+			return ImportReport{}, fmt.Errorf("could not get url: %w", err)
+		}
+
+		if existing.ShortURL != "" {
+			if existing.LongURL == record.LongURL {
+				report.Unchanged++
+				continue
+			}
+			issue.Reason = "code already points to " + existing.LongURL
+			report.Conflicts = append(report.Conflicts, issue)
+			continue
+		}
+
+		if dryRun {
+			report.Imported++
+			continue
+		}
+
+		createdAt := s.now()
+		if record.CreatedAt != nil {
+			createdAt = *record.CreatedAt
+		}
+
+		url := repository.URL{
+			ShortURL:       shortURL,
+			LongURL:        record.LongURL,
+			RedirectStatus: s.defaultRedirectStatus,
+			Hits:           record.Hits,
+			CreatedAt:      createdAt,
+		}
+
+		if ownerKeyID != 0 {
+			url.OwnerKeyID = &ownerKeyID
+		}
+
+		imported, err := s.repo.ImportURL(ctx, url)
+		if err != nil {
+			if errors.Is(err, repository.ErrOwnerNotFound) {
+				return ImportReport{}, fmt.Errorf("could not find api key %d: %w", ownerKeyID, ErrAPIKeyNotFound)
+			}
+			return ImportReport{}, fmt.Errorf("could not import url: %w", err)
+		}
+
+		// Another link took the code since it was checked.
+		if !imported {
+			issue.Reason = "code is already taken"
+			report.Conflicts = append(report.Conflicts, issue)
+			continue
+		}
+		report.Imported++
+	}
+
+	s.log(ctx).Info("imported urls",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("imported", report.Imported),
+		zap.Int("unchanged", report.Unchanged),
+		zap.Int("conflicts", len(report.Conflicts)),
+		zap.Int("invalid", len(report.Invalid)),
+	)
+	return report, nil
+}
+
+// validateImportRecord returns why record cannot be imported, or an empty
+// string when it can.
+func validateImportRecord(record ImportRecord) string {
+	if record.Code == "" || len(record.Code) > maxImportCodeLength {
+		return fmt.Sprintf("code must have between 1 and %d characters", maxImportCodeLength)
+	}
+
+	for _, r := range record.Code {
+		if !validCodeRune(r) {
+			return "code may only contain letters, digits, - and _"
+		}
+	}
+
+	if err := ValidateLongURL(record.LongURL); err != nil {
+		return "long url must be an absolute http or https url"
+	}
+
+	if record.Hits < 0 {
+		return "hits must not be negative"
+	}
+	return ""
+}
+
+// generatedCode reports whether code has the form of the codes of generated
+// short URLs.
+func generatedCode(code string) bool {
+	if len(code) != generatedCodeLength {
+		return false
+	}
+
+	for _, r := range code {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func validCodeRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_'
+}
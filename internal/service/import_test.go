@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestImportURLs(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := now.Add(-24 * time.Hour)
+
+	records := []ImportRecord{
+		{Position: 2, Code: "3xYzAb", LongURL: "https://www.foo.com/a", Hits: 12, CreatedAt: &createdAt},
+		{Position: 3, Code: "promo", LongURL: "https://www.foo.com/b"},
+		{Position: 4, Code: "taken", LongURL: "https://www.foo.com/c"},
+		{Position: 5, Code: "same", LongURL: "https://www.foo.com/d"},
+		{Position: 6, Code: "promo", LongURL: "https://www.foo.com/e"},
+		{Position: 7, Code: "7633a1", LongURL: "https://www.foo.com/i"},
+		{Position: 8, Code: "bad/code", LongURL: "https://www.foo.com/f"},
+		{Position: 9, Code: "nourl", LongURL: "www.foo.com/g"},
+		{Position: 10, Code: "raced", LongURL: "https://www.foo.com/h"},
+	}
+
+	existing := map[string]string{
+		"http://bar/taken": "https://www.other.com",
+		"http://bar/same":  "https://www.foo.com/d",
+	}
+
+	expectedConflicts := []ImportIssue{
+		{Position: 4, Code: "taken", LongURL: "https://www.foo.com/c", Reason: "code already points to https://www.other.com"},
+		{Position: 6, Code: "promo", LongURL: "https://www.foo.com/e", Reason: "code is repeated from record 3"},
+		{Position: 7, Code: "7633a1", LongURL: "https://www.foo.com/i", Reason: "code has the form of generated codes"},
+	}
+
+	expectedInvalid := []ImportIssue{
+		{Position: 8, Code: "bad/code", LongURL: "https://www.foo.com/f", Reason: "code may only contain letters, digits, - and _"},
+		{Position: 9, Code: "nourl", LongURL: "www.foo.com/g", Reason: "long url must be an absolute http or https url"},
+	}
+
+	newRepo := func(imported *[]repository.URL) *repository.Mock {
+		return &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				if longURL, ok := existing[shortURL]; ok {
+					return repository.URL{ShortURL: shortURL, LongURL: longURL}, nil
+				}
+				return repository.URL{}, nil
+			},
+			ImportURLFunc: func(ctx context.Context, url repository.URL) (bool, error) {
+				if url.ShortURL == "http://bar/raced" {
+					return false, nil
+				}
+
+				*imported = append(*imported, url)
+				return true, nil
+			},
+		}
+	}
+
+	t.Run("import", func(t *testing.T) {
+		t.Parallel()
+
+		var imported []repository.URL
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepo(&imported), WithClock(func() time.Time { return now }))
+
+		report, err := svc.ImportURLs(context.Background(), records, 0, false)
+		require.NoError(t, err)
+
+		assert.Equal(t, ImportReport{
+			Imported:  2,
+			Unchanged: 1,
+			Conflicts: append(expectedConflicts, ImportIssue{
+				Position: 10, Code: "raced", LongURL: "https://www.foo.com/h", Reason: "code is already taken",
+			}),
+			Invalid: expectedInvalid,
+		}, report)
+
+		assert.Equal(t, []repository.URL{
+			{ShortURL: "http://bar/3xYzAb", LongURL: "https://www.foo.com/a", RedirectStatus: http.StatusFound, Hits: 12, CreatedAt: createdAt},
+			{ShortURL: "http://bar/promo", LongURL: "https://www.foo.com/b", RedirectStatus: http.StatusFound, CreatedAt: now},
+		}, imported)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		t.Parallel()
+
+		var imported []repository.URL
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepo(&imported))
+
+		report, err := svc.ImportURLs(context.Background(), records, 0, true)
+		require.NoError(t, err)
+
+		assert.Equal(t, ImportReport{
+			DryRun:    true,
+			Imported:  3,
+			Unchanged: 1,
+			Conflicts: expectedConflicts,
+			Invalid:   expectedInvalid,
+		}, report)
+
+		assert.Empty(t, imported)
+	})
+
+	t.Run("import owned by api key", func(t *testing.T) {
+		t.Parallel()
+
+		var imported []repository.URL
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepo(&imported))
+
+		_, err := svc.ImportURLs(context.Background(), records[:1], 3, false)
+		require.NoError(t, err)
+
+		require.Len(t, imported, 1)
+		require.NotNil(t, imported[0].OwnerKeyID)
+		assert.Equal(t, int64(3), *imported[0].OwnerKeyID)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{}, nil
+			},
+			ImportURLFunc: func(ctx context.Context, url repository.URL) (bool, error) {
+				return false, repository.ErrOwnerNotFound
+			},
+		})
+
+		_, err := svc.ImportURLs(context.Background(), records, 4, false)
+		require.ErrorIs(t, err, ErrAPIKeyNotFound)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		t.Parallel()
+
+		errRepo := errors.New("connection refused")
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{}, errRepo
+			},
+		})
+
+		_, err := svc.ImportURLs(context.Background(), records, 0, false)
+		require.ErrorIs(t, err, errRepo)
+	})
+}
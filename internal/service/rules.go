@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alesr/urltinyizer/internal/language"
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/alesr/urltinyizer/internal/schedule"
+	"github.com/alesr/urltinyizer/internal/useragent"
+	"go.uber.org/zap"
+)
+
+// Kinds of redirect rules.
+const (
+	RuleKindDevice   = "device"
+	RuleKindLanguage = "language"
+	RuleKindCountry  = "country"
+	RuleKindSchedule = "schedule"
+)
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Conditions accepted by device rules, besides the platforms known by the useragent package.
+const (
+	DeviceMobile  = "mobile"
+	DeviceDesktop = "desktop"
+)
+
+var deviceConditions = map[string]bool{
+	useragent.PlatformIOS:     true,
+	useragent.PlatformAndroid: true,
+	useragent.PlatformWindows: true,
+	useragent.PlatformMacOS:   true,
+	useragent.PlatformLinux:   true,
+	DeviceMobile:              true,
+	DeviceDesktop:             true,
+}
+
+func (s *ServiceDefault) AddRule(ctx context.Context, shortURL string, in RuleInput) (Rule, error) {
+	condition, err := normalizeRule(in)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	url, err := s.repo.GetURL(ctx, shortURL)
+	if err != nil {
+		return Rule{}, fmt.Errorf("could not get url: %w", err)
+	}
+
+	if url.LongURL == "" {
+		return Rule{}, fmt.Errorf("could not find url for short url %s: %w", shortURL, ErrNotFound)
+	}
+
+	rule, err := s.repo.SaveRule(ctx, repository.Rule{
+		ShortURL:  shortURL,
+		Kind:      in.Kind,
+		Condition: condition,
+		TargetURL: in.TargetURL,
+		Priority:  in.Priority,
+	})
+	if err != nil {
+		return Rule{}, fmt.Errorf("could not save rule: %w", err)
+	}
+	return newRule(rule), nil
+}
+
+func (s *ServiceDefault) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	rules, err := s.repo.ListRules(ctx, shortURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not list rules: %w", err)
+	}
+
+	result := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, newRule(rule))
+	}
+	return result, nil
+}
+
+func (s *ServiceDefault) DeleteRule(ctx context.Context, shortURL string, id int64) error {
+	deleted, err := s.repo.DeleteRule(ctx, shortURL, id)
+	if err != nil {
+		return fmt.Errorf("could not delete rule: %w", err)
+	}
+
+	if !deleted {
+		return fmt.Errorf("could not find rule %d for short url %s: %w", id, shortURL, ErrRuleNotFound)
+	}
+	return nil
+}
+
+// resolveTarget returns the target of the first rule matching the request,
+// or an empty string when none matches, and whether the short URL has rules,
+// in which case the target depends on the request.
+//
+// Language rules are evaluated together, at the position of the first one:
+// the client's Accept-Language preferences pick the best of their languages.
+func (s *ServiceDefault) resolveTarget(ctx context.Context, in RedirectInput) (string, bool, error) {
+	rules, err := s.repo.ListRules(ctx, in.ShortURL)
+	if err != nil {
+		return "", false, fmt.Errorf("could not list rules: %w", err)
+	}
+
+	if len(rules) == 0 {
+		return "", false, nil
+	}
+
+	client := useragent.Parse(in.UserAgent)
+	languagesMatched := false
+
+	var (
+		country         string
+		countryResolved bool
+		now             = s.now()
+	)
+
+	for _, rule := range rules {
+		switch rule.Kind {
+		case RuleKindCountry:
+			if !countryResolved {
+				country = s.resolveCountry(ctx, in)
+				countryResolved = true
+			}
+
+			if country != "" && rule.Condition == country {
+				return rule.TargetURL, true, nil
+			}
+		case RuleKindDevice:
+			if matchDevice(rule.Condition, client) {
+				return rule.TargetURL, true, nil
+			}
+		case RuleKindSchedule:
+			sch, err := schedule.Parse(rule.Condition)
+			if err != nil {
+				s.log(ctx).Warn("could not parse schedule", zap.Int64("rule_id", rule.ID), zap.Error(err))
+				continue
+			}
+
+			if sch.Contains(now) {
+				return rule.TargetURL, true, nil
+			}
+		case RuleKindLanguage:
+			if languagesMatched {
+				continue
+			}
+			languagesMatched = true
+
+			if target, ok := matchLanguage(rules, in.AcceptLanguage); ok {
+				return target, true, nil
+			}
+		}
+	}
+	return "", true, nil
+}
+
+// resolveCountry returns the country of the client, or an empty string when
+// it is unknown or no country resolver is configured.
+func (s *ServiceDefault) resolveCountry(ctx context.Context, in RedirectInput) string {
+	if s.countryResolver == nil || in.ClientIP == nil {
+		return ""
+	}
+
+	country, err := s.countryResolver.Country(in.ClientIP)
+	if err != nil {
+		s.log(ctx).Warn("could not resolve country", zap.Error(err))
+		return ""
+	}
+	return strings.ToUpper(country)
+}
+
+// normalizeRule validates a rule and returns its condition in canonical form.
+func normalizeRule(in RuleInput) (string, error) {
+	condition := strings.ToLower(strings.TrimSpace(in.Condition))
+
+	switch in.Kind {
+	case RuleKindCountry:
+		condition = strings.ToUpper(condition)
+		if !countryCodePattern.MatchString(condition) {
+			return "", fmt.Errorf("invalid country code %q: %w", in.Condition, ErrInvalidRule)
+		}
+		return condition, nil
+	case RuleKindDevice:
+		if !deviceConditions[condition] {
+			return "", fmt.Errorf("unknown device %q: %w", in.Condition, ErrInvalidRule)
+		}
+		return condition, nil
+	case RuleKindLanguage:
+		if !language.ValidTag(condition) {
+			return "", fmt.Errorf("invalid language tag %q: %w", in.Condition, ErrInvalidRule)
+		}
+		return condition, nil
+	case RuleKindSchedule:
+		sch, err := schedule.Parse(in.Condition)
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", err, ErrInvalidRule)
+		}
+		return sch.String(), nil
+	}
+	return "", fmt.Errorf("unknown rule kind %q: %w", in.Kind, ErrInvalidRule)
+}
+
+func matchDevice(condition string, client useragent.Info) bool {
+	switch condition {
+	case DeviceMobile:
+		return client.Mobile
+	case DeviceDesktop:
+		return client.Desktop()
+	}
+	return client.Platform == condition
+}
+
+// matchLanguage returns the target of the language rule best matching the
+// Accept-Language header. Rules sharing a language keep the first one.
+func matchLanguage(rules []repository.Rule, acceptLanguage string) (string, bool) {
+	prefs := language.ParseAcceptLanguage(acceptLanguage)
+	if len(prefs) == 0 {
+		return "", false
+	}
+
+	var tags []string
+	targets := make(map[string]string)
+
+	for _, rule := range rules {
+		if rule.Kind != RuleKindLanguage {
+			continue
+		}
+
+		if _, ok := targets[rule.Condition]; !ok {
+			tags = append(tags, rule.Condition)
+			targets[rule.Condition] = rule.TargetURL
+		}
+	}
+
+	tag, ok := language.Match(prefs, tags)
+	if !ok {
+		return "", false
+	}
+	return targets[tag], true
+}
+
+func newRule(rule repository.Rule) Rule {
+	return Rule{
+		ID:        rule.ID,
+		Kind:      rule.Kind,
+		Condition: rule.Condition,
+		TargetURL: rule.TargetURL,
+		Priority:  rule.Priority,
+		CreatedAt: rule.CreatedAt,
+	}
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.3 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Mobile Safari/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36"
+)
+
+func newRulesRepoMock(rules ...repository.Rule) *repository.Mock {
+	return &repository.Mock{
+		GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+			return repository.URL{
+				ShortURL:       shortURL,
+				LongURL:        "https://www.foo.com",
+				RedirectStatus: http.StatusFound,
+			}, nil
+		},
+		RecordHitFunc: func(ctx context.Context, hit repository.Hit) error {
+			return nil
+		},
+		AddVisitorFunc: func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+			return nil
+		},
+		ListRulesFunc: func(ctx context.Context, shortURL string) ([]repository.Rule, error) {
+			return rules, nil
+		},
+		ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+			return nil, nil
+		},
+	}
+}
+
+func TestDeviceRules(t *testing.T) {
+	t.Parallel()
+
+	repoMock := newRulesRepoMock(
+		repository.Rule{ID: 1, Kind: RuleKindDevice, Condition: "ios", TargetURL: "https://apps.apple.com/app"},
+		repository.Rule{ID: 2, Kind: RuleKindDevice, Condition: "android", TargetURL: "https://play.google.com/app"},
+	)
+
+	testCases := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:      "ios",
+			userAgent: iPhoneUA,
+			expected:  "https://apps.apple.com/app",
+		},
+		{
+			name:      "android",
+			userAgent: androidUA,
+			expected:  "https://play.google.com/app",
+		},
+		{
+			name:      "desktop falls back to long url",
+			userAgent: windowsUA,
+			expected:  "https://www.foo.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+			observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+				ShortURL:  "http://bar/7633a1",
+				UserAgent: tc.userAgent,
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, observed.LongURL)
+
+			// Even the fallback depends on the visitor, so it must not be cached.
+			require.True(t, observed.RulesEvaluated)
+		})
+	}
+}
+
+func TestLanguageRules(t *testing.T) {
+	t.Parallel()
+
+	repoMock := newRulesRepoMock(
+		repository.Rule{ID: 1, Kind: RuleKindDevice, Condition: "android", TargetURL: "https://play.google.com/app"},
+		repository.Rule{ID: 2, Kind: RuleKindLanguage, Condition: "pt-br", TargetURL: "https://www.foo.com/br"},
+		repository.Rule{ID: 3, Kind: RuleKindLanguage, Condition: "pt", TargetURL: "https://www.foo.com/pt"},
+		repository.Rule{ID: 4, Kind: RuleKindLanguage, Condition: "de", TargetURL: "https://www.foo.com/de"},
+	)
+
+	testCases := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		expected       string
+	}{
+		{
+			name:           "exact language",
+			acceptLanguage: "pt-BR,pt;q=0.9",
+			expected:       "https://www.foo.com/br",
+		},
+		{
+			name:           "base language",
+			acceptLanguage: "pt-PT",
+			expected:       "https://www.foo.com/pt",
+		},
+		{
+			name:           "quality values",
+			acceptLanguage: "pt;q=0.4, de;q=0.7, en",
+			expected:       "https://www.foo.com/de",
+		},
+		{
+			name:           "falls back to long url",
+			acceptLanguage: "en-US,en;q=0.9",
+			expected:       "https://www.foo.com",
+		},
+		{
+			name:           "higher priority rule wins",
+			userAgent:      androidUA,
+			acceptLanguage: "de",
+			expected:       "https://play.google.com/app",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+			observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+				ShortURL:       "http://bar/7633a1",
+				UserAgent:      tc.userAgent,
+				AcceptLanguage: tc.acceptLanguage,
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, observed.LongURL)
+		})
+	}
+}
+
+type countryResolverStub map[string]string
+
+func (c countryResolverStub) Country(ip net.IP) (string, error) {
+	return c[ip.String()], nil
+}
+
+func TestCountryRules(t *testing.T) {
+	t.Parallel()
+
+	repoMock := newRulesRepoMock(
+		repository.Rule{ID: 1, Kind: RuleKindCountry, Condition: "PT", TargetURL: "https://www.foo.com/pt"},
+		repository.Rule{ID: 2, Kind: RuleKindCountry, Condition: "BR", TargetURL: "https://www.foo.com/br"},
+	)
+
+	resolver := countryResolverStub{
+		"192.0.2.1":   "PT",
+		"192.0.2.2":   "br",
+		"192.0.2.3":   "US",
+		"2001:db8::1": "BR",
+	}
+
+	testCases := []struct {
+		name     string
+		clientIP net.IP
+		expected string
+	}{
+		{name: "ipv4", clientIP: net.ParseIP("192.0.2.1"), expected: "https://www.foo.com/pt"},
+		{name: "lowercase country", clientIP: net.ParseIP("192.0.2.2"), expected: "https://www.foo.com/br"},
+		{name: "ipv6", clientIP: net.ParseIP("2001:db8::1"), expected: "https://www.foo.com/br"},
+		{name: "other country", clientIP: net.ParseIP("192.0.2.3"), expected: "https://www.foo.com"},
+		{name: "unknown country", clientIP: net.ParseIP("198.51.100.1"), expected: "https://www.foo.com"},
+		{name: "no client ip", expected: "https://www.foo.com"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithCountryResolver(resolver))
+
+			observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+				ShortURL: "http://bar/7633a1",
+				ClientIP: tc.clientIP,
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, observed.LongURL)
+		})
+	}
+
+	t.Run("without resolver", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+			ShortURL: "http://bar/7633a1",
+			ClientIP: net.ParseIP("192.0.2.1"),
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, "https://www.foo.com", observed.LongURL)
+	})
+}
+
+func TestScheduleRules(t *testing.T) {
+	t.Parallel()
+
+	repoMock := newRulesRepoMock(
+		repository.Rule{ID: 1, Kind: RuleKindSchedule, Condition: "mon,tue,wed,thu,fri 09:00-17:00 Europe/Lisbon", TargetURL: "https://www.foo.com/chat"},
+	)
+
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected string
+	}{
+		{
+			name:     "within office hours",
+			now:      time.Date(2023, 7, 3, 8, 0, 0, 0, time.UTC), // Monday, 09:00 in Lisbon.
+			expected: "https://www.foo.com/chat",
+		},
+		{
+			name:     "after office hours",
+			now:      time.Date(2023, 7, 3, 16, 0, 0, 0, time.UTC), // Monday, 17:00 in Lisbon.
+			expected: "https://www.foo.com",
+		},
+		{
+			name:     "weekend",
+			now:      time.Date(2023, 7, 8, 10, 0, 0, 0, time.UTC),
+			expected: "https://www.foo.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time {
+				return tc.now
+			}))
+
+			observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+				ShortURL: "http://bar/7633a1",
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, observed.LongURL)
+		})
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	t.Parallel()
+
+	t.Run("add rule", func(t *testing.T) {
+		t.Parallel()
+
+		repoMock := newRulesRepoMock()
+		repoMock.SaveRuleFunc = func(ctx context.Context, rule repository.Rule) (repository.Rule, error) {
+			rule.ID = 1
+			return rule, nil
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.AddRule(context.Background(), "http://bar/7633a1", RuleInput{
+			Kind:      RuleKindDevice,
+			Condition: " Desktop ",
+			TargetURL: "https://www.foo.com/desktop",
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, int64(1), observed.ID)
+		require.Equal(t, DeviceDesktop, observed.Condition)
+	})
+
+	t.Run("add schedule rule", func(t *testing.T) {
+		t.Parallel()
+
+		repoMock := newRulesRepoMock()
+		repoMock.SaveRuleFunc = func(ctx context.Context, rule repository.Rule) (repository.Rule, error) {
+			rule.ID = 1
+			return rule, nil
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.AddRule(context.Background(), "http://bar/7633a1", RuleInput{
+			Kind:      RuleKindSchedule,
+			Condition: "Weekdays 9-17 Europe/Lisbon",
+			TargetURL: "https://www.foo.com/chat",
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, "mon,tue,wed,thu,fri 09:00-17:00 Europe/Lisbon", observed.Condition)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRulesRepoMock())
+
+		_, err := svc.AddRule(context.Background(), "http://bar/7633a1", RuleInput{
+			Kind:      RuleKindDevice,
+			Condition: "toaster",
+			TargetURL: "https://www.foo.com/toaster",
+		})
+		require.ErrorIs(t, err, ErrInvalidRule)
+	})
+
+	t.Run("short url not found", func(t *testing.T) {
+		t.Parallel()
+
+		repoMock := &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		_, err := svc.AddRule(context.Background(), "http://bar/7633a1", RuleInput{
+			Kind:      RuleKindDevice,
+			Condition: "ios",
+			TargetURL: "https://apps.apple.com/app",
+		})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestDeleteRule(t *testing.T) {
+	t.Parallel()
+
+	repoMock := &repository.Mock{
+		DeleteRuleFunc: func(ctx context.Context, shortURL string, id int64) (bool, error) {
+			return id == 1, nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+	require.NoError(t, svc.DeleteRule(context.Background(), "http://bar/7633a1", 1))
+	require.ErrorIs(t, svc.DeleteRule(context.Background(), "http://bar/7633a1", 2), ErrRuleNotFound)
+}
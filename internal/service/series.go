@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+)
+
+// maxBuckets bounds the length of a hit time series.
+const maxBuckets = 10000
+
+// bucketLayouts format the wall clock time identifying a bucket of each interval.
+var bucketLayouts = map[string]string{
+	IntervalMinute: "2006-01-02T15:04",
+	IntervalHour:   "2006-01-02T15",
+	IntervalDay:    time.DateOnly,
+	IntervalWeek:   time.DateOnly,
+}
+
+// validateSeries checks that the time series of a stats query is bounded.
+func validateSeries(query StatsQuery) error {
+	if _, ok := bucketLayouts[query.Interval]; !ok {
+		return fmt.Errorf("unknown interval %q: %w", query.Interval, ErrInvalidStatsQuery)
+	}
+
+	if query.From.IsZero() {
+		return fmt.Errorf("interval requires from: %w", ErrInvalidStatsQuery)
+	}
+
+	if n := len(bucketStarts(query, maxBuckets+1)); n > maxBuckets {
+		return fmt.Errorf("time range spans more than %d %ss: %w", maxBuckets, query.Interval, ErrInvalidStatsQuery)
+	}
+	return nil
+}
+
+// hitSeries returns the hits of a short URL per interval of the query,
+// including the intervals without hits.
+func (s *ServiceDefault) hitSeries(ctx context.Context, shortURL string, query StatsQuery) ([]Bucket, error) {
+	counts, err := s.repo.HitSeries(ctx, repository.HitSeriesQuery{
+		ShortURL: shortURL,
+		From:     query.From,
+		To:       query.To,
+		Interval: query.Interval,
+		Location: query.Location.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not get hit series: %w", err)
+	}
+
+	layout := bucketLayouts[query.Interval]
+
+	hits := make(map[string]int, len(counts))
+	for _, c := range counts {
+		hits[c.Start.Format(layout)] += c.Hits
+	}
+
+	starts := bucketStarts(query, maxBuckets)
+
+	series := make([]Bucket, 0, len(starts))
+	for _, start := range starts {
+		series = append(series, Bucket{
+			Start: start,
+			Hits:  hits[start.Format(layout)],
+		})
+	}
+	return series, nil
+}
+
+// bucketStarts returns the start of each interval overlapping [From, To),
+// at most limit of them. Buckets follow the wall clock of the query time
+// zone, so days and weeks keep starting at midnight across DST changes.
+func bucketStarts(query StatsQuery, limit int) []time.Time {
+	var (
+		starts []time.Time
+		from   = query.From.In(query.Location)
+		layout = bucketLayouts[query.Interval]
+		seen   = make(map[string]bool)
+	)
+
+	switch query.Interval {
+	case IntervalMinute, IntervalHour:
+		// Truncate by the local clock, as zones may be offset by a fraction of an hour.
+		step := time.Minute
+		first := from.Add(-time.Duration(from.Second())*time.Second - time.Duration(from.Nanosecond()))
+		if query.Interval == IntervalHour {
+			step = time.Hour
+			first = first.Add(-time.Duration(from.Minute()) * time.Minute)
+		}
+
+		// Step in absolute time, skipping the wall clock times repeated when clocks go back.
+		for t := first; t.Before(query.To) && len(starts) < limit; t = t.Add(step) {
+			local := t.In(query.Location)
+			if key := local.Format(layout); !seen[key] {
+				seen[key] = true
+				starts = append(starts, local)
+			}
+		}
+	case IntervalDay, IntervalWeek:
+		days := 1
+		offset := 0
+		if query.Interval == IntervalWeek {
+			days = 7
+			offset = (int(from.Weekday()) + 6) % 7
+		}
+
+		// Step through calendar dates, which are unaffected by DST.
+		date := time.Date(from.Year(), from.Month(), from.Day()-offset, 0, 0, 0, 0, time.UTC)
+		for len(starts) < limit {
+			start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, query.Location)
+			if !start.Before(query.To) {
+				break
+			}
+
+			starts = append(starts, start)
+			date = date.AddDate(0, 0, days)
+		}
+	}
+	return starts
+}
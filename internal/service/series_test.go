@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBucketStarts(t *testing.T) {
+	t.Parallel()
+
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	require.NoError(t, err)
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		query    StatsQuery
+		expected []string
+	}{
+		{
+			name: "hours in a half hour offset",
+			query: StatsQuery{
+				From:     time.Date(2023, 7, 3, 0, 45, 0, 0, time.UTC),
+				To:       time.Date(2023, 7, 3, 2, 45, 0, 0, time.UTC),
+				Interval: IntervalHour,
+				Location: kolkata,
+			},
+			expected: []string{"2023-07-03T06:00:00+05:30", "2023-07-03T07:00:00+05:30", "2023-07-03T08:00:00+05:30"},
+		},
+		{
+			name: "hours when clocks go back",
+			query: StatsQuery{
+				From:     time.Date(2023, 10, 29, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(2023, 10, 29, 3, 0, 0, 0, time.UTC),
+				Interval: IntervalHour,
+				Location: lisbon,
+			},
+			expected: []string{"2023-10-29T01:00:00+01:00", "2023-10-29T02:00:00Z"},
+		},
+		{
+			name: "days across a dst change",
+			query: StatsQuery{
+				From:     time.Date(2023, 3, 25, 12, 0, 0, 0, lisbon),
+				To:       time.Date(2023, 3, 27, 12, 0, 0, 0, lisbon),
+				Interval: IntervalDay,
+				Location: lisbon,
+			},
+			expected: []string{"2023-03-25T00:00:00Z", "2023-03-26T00:00:00Z", "2023-03-27T00:00:00+01:00"},
+		},
+		{
+			name: "weeks start on monday",
+			query: StatsQuery{
+				From:     time.Date(2023, 7, 5, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(2023, 7, 11, 0, 0, 0, 0, time.UTC),
+				Interval: IntervalWeek,
+				Location: time.UTC,
+			},
+			expected: []string{"2023-07-03T00:00:00Z", "2023-07-10T00:00:00Z"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var observed []string
+			for _, start := range bucketStarts(tc.query, maxBuckets) {
+				observed = append(observed, start.Format(time.RFC3339))
+			}
+
+			require.Equal(t, tc.expected, observed)
+		})
+	}
+}
+
+func TestGetStatsSeries(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 6, 12, 0, 0, 0, time.UTC)
+
+	repoMock := &repository.Mock{
+		GetStatsFunc: func(ctx context.Context, shortURL string) (repository.Stats, error) {
+			return repository.Stats{Hits: 5}, nil
+		},
+		ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+			return nil, nil
+		},
+		ListVisitorSketchesFunc: func(ctx context.Context, shortURL string) ([]repository.VisitorSketch, error) {
+			return nil, nil
+		},
+		TopSourcesFunc: func(ctx context.Context, query repository.TopSourcesQuery) ([]repository.SourceCount, error) {
+			return nil, nil
+		},
+		HitSeriesFunc: func(ctx context.Context, query repository.HitSeriesQuery) ([]repository.Bucket, error) {
+			require.Equal(t, IntervalDay, query.Interval)
+			require.Equal(t, "America/New_York", query.Location)
+
+			return []repository.Bucket{
+				{Start: time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC), Hits: 2},
+				{Start: time.Date(2023, 7, 5, 0, 0, 0, 0, time.UTC), Hits: 3},
+			}, nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time {
+		return now
+	}))
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	t.Run("zero buckets are filled", func(t *testing.T) {
+		t.Parallel()
+
+		observed, err := svc.GetStats(context.Background(), "http://bar/7633a1", StatsQuery{
+			From:     time.Date(2023, 7, 3, 0, 0, 0, 0, newYork),
+			Interval: IntervalDay,
+			Location: newYork,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []Bucket{
+			{Start: time.Date(2023, 7, 3, 0, 0, 0, 0, newYork), Hits: 2},
+			{Start: time.Date(2023, 7, 4, 0, 0, 0, 0, newYork), Hits: 0},
+			{Start: time.Date(2023, 7, 5, 0, 0, 0, 0, newYork), Hits: 3},
+			{Start: time.Date(2023, 7, 6, 0, 0, 0, 0, newYork), Hits: 0},
+		}, observed.Series)
+	})
+
+	testCases := []struct {
+		name  string
+		query StatsQuery
+	}{
+		{name: "unknown interval", query: StatsQuery{From: now.Add(-time.Hour), Interval: "fortnight"}},
+		{name: "interval without from", query: StatsQuery{Interval: IntervalDay}},
+		{name: "too many buckets", query: StatsQuery{From: now.AddDate(-1, 0, 0), Interval: IntervalMinute}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := svc.GetStats(context.Background(), "http://bar/7633a1", tc.query)
+			require.ErrorIs(t, err, ErrInvalidStatsQuery)
+		})
+	}
+}
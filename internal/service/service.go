@@ -1,10 +1,324 @@
 package service
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a short URL does not exist.
+	ErrNotFound = errors.New("short url not found")
+
+	// ErrPasswordRequired is returned when redirecting a password-protected
+	// short URL without a valid unlock token.
+	ErrPasswordRequired = errors.New("short url is password protected")
+
+	// ErrInvalidPassword is returned when unlocking a short URL with the wrong password.
+	ErrInvalidPassword = errors.New("invalid password")
+
+	// ErrNotActive is returned when a short URL is used before its activation time.
+	ErrNotActive = errors.New("short url is not active yet")
+
+	// ErrExpired is returned when a short URL is used after its deactivation time.
+	ErrExpired = errors.New("short url has expired")
+
+	// ErrInvalidRule is returned when a redirect rule has an unknown kind or condition.
+	ErrInvalidRule = errors.New("invalid rule")
+
+	// ErrRuleNotFound is returned when a redirect rule does not exist.
+	ErrRuleNotFound = errors.New("rule not found")
+
+	// ErrInvalidVariant is returned when a set of variants is not valid.
+	ErrInvalidVariant = errors.New("invalid variant")
+
+	// ErrInvalidStatsQuery is returned when the time range or paging of a stats query is not valid.
+	ErrInvalidStatsQuery = errors.New("invalid stats query")
+
+	// ErrInvalidAPIKeyName is returned when creating an API key without a valid name.
+	ErrInvalidAPIKeyName = errors.New("invalid api key name")
+
+	// ErrAPIKeyNotFound is returned when an API key does not exist.
+	ErrAPIKeyNotFound = errors.New("api key not found")
+
+	// ErrInvalidAPIKey is returned when authenticating with an unknown or revoked API key.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+
+	// ErrInvalidURL is returned when shortening a long URL that is not an absolute http or https URL.
+	ErrInvalidURL = errors.New("invalid url")
+)
 
 // Service is an interface that defines the methods that a service should implement.
 type Service interface {
-	CreateShortURL(ctx context.Context, longURL string) (string, error)
-	RedirectToLongURL(ctx context.Context, shortURL string) (string, error)
-	GetStats(ctx context.Context, shortURL string) (int, error)
+	CreateShortURL(ctx context.Context, in CreateShortURLInput) (string, error)
+	RedirectToLongURL(ctx context.Context, in RedirectInput) (Redirect, error)
+	UnlockURL(ctx context.Context, shortURL, password string) (UnlockToken, error)
+	GetURL(ctx context.Context, shortURL string) (URL, error)
+	GetStats(ctx context.Context, shortURL string, query StatsQuery) (Stats, error)
+	TopLinks(ctx context.Context, window time.Duration, limit int) ([]LinkHits, error)
+	AddRule(ctx context.Context, shortURL string, in RuleInput) (Rule, error)
+	ListRules(ctx context.Context, shortURL string) ([]Rule, error)
+	DeleteRule(ctx context.Context, shortURL string, id int64) error
+	SetVariants(ctx context.Context, shortURL string, in VariantSet) (VariantSet, error)
+	GetVariants(ctx context.Context, shortURL string) (VariantSet, error)
+	DeleteURL(ctx context.Context, shortURL string) error
+	CreateAPIKey(ctx context.Context, name string) (APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error)
+	ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error
+	ImportURLs(ctx context.Context, records []ImportRecord, ownerKeyID int64, dryRun bool) (ImportReport, error)
+}
+
+// CreateShortURLInput holds the parameters for creating a short URL.
+// A zero RedirectStatus means the service default is used, a non-empty
+// Password protects the link, and NotBefore and NotAfter bound the window
+// in which the link redirects. A non-zero OwnerKeyID is the API key the
+// link is created with.
+type CreateShortURLInput struct {
+	LongURL        string
+	RedirectStatus int
+	Password       string
+	NotBefore      *time.Time
+	NotAfter       *time.Time
+	OwnerKeyID     int64
+}
+
+// RedirectInput holds the parameters for resolving a short URL.
+// UnlockToken is required for password-protected links, VariantID is the
+// variant a sticky visitor was given before, and the remaining fields
+// describe the client for evaluating redirect rules.
+type RedirectInput struct {
+	ShortURL       string
+	UnlockToken    string
+	VariantID      int64
+	UserAgent      string
+	AcceptLanguage string
+	ClientIP       net.IP
+	Referrer       string
+	UTMSource      string
+}
+
+// CountryResolver resolves the ISO 3166-1 alpha-2 country code of an IP address.
+// It returns an empty string when the country is unknown.
+type CountryResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+// RuleInput holds the parameters for creating a redirect rule.
+// Rules are evaluated by ascending Priority and the first match wins.
+type RuleInput struct {
+	Kind      string
+	Condition string
+	TargetURL string
+	Priority  int
+}
+
+// Rule is a redirect rule of a short URL.
+type Rule struct {
+	ID        int64
+	Kind      string
+	Condition string
+	TargetURL string
+	Priority  int
+	CreatedAt time.Time
+}
+
+// UnlockToken proves that a password-protected short URL was unlocked.
+type UnlockToken struct {
+	Value     string
+	ExpiresAt time.Time
+}
+
+// Redirect describes where and how a short URL should be redirected.
+// VariantID is set when the destination is one of the link's variants, and
+// StickyVariant tells whether the visitor should keep that variant.
+// PasswordProtected is set when the visitor had to unlock the link, and
+// RulesEvaluated when the link has redirect rules, which make the destination
+// depend on the visitor's device, language, country or the time.
+type Redirect struct {
+	LongURL           string
+	StatusCode        int
+	VariantID         int64
+	StickyVariant     bool
+	PasswordProtected bool
+	RulesEvaluated    bool
+}
+
+// VariantSet is the set of weighted destinations a short URL rotates between.
+type VariantSet struct {
+	Sticky   bool
+	Variants []Variant
+}
+
+// Variant is a weighted destination of a short URL.
+type Variant struct {
+	ID        int64
+	TargetURL string
+	Weight    int
+	Hits      int
+}
+
+// BotDetector classifies requests made by crawlers, link unfurlers and scanners.
+type BotDetector interface {
+	IsBot(userAgent string, ip net.IP) bool
+}
+
+// Intervals a hit time series can be bucketed by.
+const (
+	IntervalMinute = "minute"
+	IntervalHour   = "hour"
+	IntervalDay    = "day"
+	IntervalWeek   = "week"
+)
+
+// StatsQuery selects the time range, [From, To), and the page of the top
+// sources returned with stats. A zero From or To leaves the range open.
+//
+// With an Interval, the hits in the range are also returned as a time series
+// with buckets aligned to the Location time zone, UTC by default. Weeks start
+// on Monday. A time series needs a From.
+type StatsQuery struct {
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+	Interval string
+	Location *time.Location
+}
+
+// Stats holds the usage statistics of a short URL.
+// Hits, unique visitors and variant hits only count requests not made by bots.
+type Stats struct {
+	Hits                int
+	BotHits             int
+	UniqueVisitors      int
+	DailyUniqueVisitors []DailyVisitors
+	Variants            []Variant
+
+	// Top sources of the hits within the time range of the query.
+	Referrers         []SourceCount
+	UTMSources        []SourceCount
+	UserAgentFamilies []SourceCount
+
+	// Hits within the time range of the query, per interval.
+	Series []Bucket
+}
+
+// LinkHits is the number of hits of a short URL within a window.
+type LinkHits struct {
+	ShortURL string
+	Hits     int
+}
+
+// Bucket is the number of hits in the interval starting at Start.
+type Bucket struct {
+	Start time.Time
+	Hits  int
+}
+
+// SourceCount is the number of hits coming from a referrer, UTM source or browser family.
+type SourceCount struct {
+	Value string
+	Hits  int
+}
+
+// DailyVisitors is the approximate number of unique visitors of a short URL on a day.
+type DailyVisitors struct {
+	Day            time.Time
+	UniqueVisitors int
+}
+
+// URL describes a stored short URL. OwnerKeyID is the API key the short URL
+// was created with, or zero.
+type URL struct {
+	ShortURL          string
+	LongURL           string
+	RedirectStatus    int
+	PasswordProtected bool
+	NotBefore         *time.Time
+	NotAfter          *time.Time
+	Active            bool
+	Hits              int
+	CreatedAt         time.Time
+	OwnerKeyID        int64
+}
+
+// ExportedURL is a short URL with its hit counters, as listed by an export.
+// LastHitAt is nil for short URLs that were never hit.
+type ExportedURL struct {
+	ShortURL  string
+	LongURL   string
+	CreatedAt time.Time
+	Hits      int
+	LastHitAt *time.Time
+}
+
+// ImportRecord is a link exported from another URL shortener. Position is
+// the line of the record in a CSV export, or its index from 1 in a JSON one,
+// and CreatedAt is nil when the export does not include it.
+type ImportRecord struct {
+	Position  int
+	Code      string
+	LongURL   string
+	Hits      int
+	CreatedAt *time.Time
+}
+
+// ImportReport is the outcome of an import. Unchanged counts the records
+// already imported with the same long URL. In a dry run nothing is saved and
+// Imported counts the records that would be.
+type ImportReport struct {
+	DryRun    bool
+	Imported  int
+	Unchanged int
+	Conflicts []ImportIssue
+	Invalid   []ImportIssue
+}
+
+// ImportIssue is a record that was not imported, and the reason why.
+type ImportIssue struct {
+	Position int
+	Code     string
+	LongURL  string
+	Reason   string
+}
+
+// APIKey is a credential of an API client. Key, the secret itself, is only
+// set when the API key is created since just its hash is stored.
+type APIKey struct {
+	ID        int64
+	Name      string
+	Key       string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+// ValidRedirectStatus reports whether code is a redirect status a link may use.
+func ValidRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// maxLongURLLength is the length of the longest long URL that can be shortened (2MB).
+const maxLongURLLength = 2048 * 1024
+
+// ValidateLongURL returns ErrInvalidURL when longURL is not an absolute http
+// or https URL that can be shortened.
+func ValidateLongURL(longURL string) error {
+	if len(longURL) > maxLongURLLength {
+		return fmt.Errorf("long url is too large: %w", ErrInvalidURL)
+	}
+
+	u, err := url.Parse(longURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http or https url: %w", longURL, ErrInvalidURL)
+	}
+	return nil
 }
@@ -2,32 +2,188 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha1"
+	"errors"
 	"fmt"
 	"io"
+	mathrand "math/rand"
+	"net/http"
+	"time"
 
+	"github.com/alesr/urltinyizer/internal/leaderboard"
 	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/alesr/urltinyizer/internal/requestid"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultUnlockTTL   = time.Hour
+	unlockSecretLength = 32
+	visitorSaltLength  = 32
+	uniqueSeedLength   = 16
+	maxSaveAttempts    = 5
+
+	// generatedCodeLength is the number of hex digits of generated codes.
+	generatedCodeLength = 6
 )
 
 var _ Service = (*ServiceDefault)(nil)
 
 type ServiceDefault struct {
-	logger  *zap.Logger
-	appHost string
-	repo    repository.Repository
+	logger                *zap.Logger
+	appHost               string
+	repo                  repository.Repository
+	defaultRedirectStatus int
+	unlockSecret          []byte
+	unlockTTL             time.Duration
+	now                   func() time.Time
+	countryResolver       CountryResolver
+	visitorSalt           []byte
+	botDetector           BotDetector
+	leaderboard           *leaderboard.Counter
+	randIntn              func(n int) int
+}
+
+// Option configures optional behaviour of ServiceDefault.
+type Option func(*ServiceDefault)
+
+// WithDefaultRedirectStatus sets the redirect status used for links created without one.
+func WithDefaultRedirectStatus(code int) Option {
+	return func(s *ServiceDefault) {
+		s.defaultRedirectStatus = code
+	}
+}
+
+// WithUnlockSecret sets the key used to sign unlock tokens of password-protected links.
+// Without it a random key is generated, so tokens do not survive a restart.
+func WithUnlockSecret(secret []byte) Option {
+	return func(s *ServiceDefault) {
+		s.unlockSecret = secret
+	}
+}
+
+// WithUnlockTTL sets how long an unlock token stays valid.
+func WithUnlockTTL(ttl time.Duration) Option {
+	return func(s *ServiceDefault) {
+		s.unlockTTL = ttl
+	}
+}
+
+// WithClock sets the function used to read the current time.
+func WithClock(now func() time.Time) Option {
+	return func(s *ServiceDefault) {
+		s.now = now
+	}
+}
+
+// WithCountryResolver sets the resolver used to evaluate country redirect rules.
+// Without it country rules never match.
+func WithCountryResolver(r CountryResolver) Option {
+	return func(s *ServiceDefault) {
+		s.countryResolver = r
+	}
 }
 
-func NewServiceDefault(logger *zap.Logger, appHost string, repo repository.Repository) *ServiceDefault {
-	return &ServiceDefault{
-		logger:  logger,
-		appHost: appHost,
-		repo:    repo,
+// WithVisitorSalt sets the salt mixed into the hashes identifying unique visitors.
+// Without it a random salt is generated, so visitors are counted again after a restart.
+func WithVisitorSalt(salt []byte) Option {
+	return func(s *ServiceDefault) {
+		s.visitorSalt = salt
+	}
+}
+
+// WithBotDetector sets the detector used to count hits made by bots separately.
+// Without it every hit is counted as human.
+func WithBotDetector(d BotDetector) Option {
+	return func(s *ServiceDefault) {
+		s.botDetector = d
+	}
+}
+
+func NewServiceDefault(logger *zap.Logger, appHost string, repo repository.Repository, opts ...Option) *ServiceDefault {
+	s := &ServiceDefault{
+		logger:                logger,
+		appHost:               appHost,
+		repo:                  repo,
+		defaultRedirectStatus: http.StatusFound,
+		unlockTTL:             defaultUnlockTTL,
+		now:                   time.Now,
+		randIntn:              mathrand.Intn,
+		leaderboard:           leaderboard.New(leaderboardResolution, leaderboardRetention),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if len(s.unlockSecret) == 0 {
+		s.unlockSecret = make([]byte, unlockSecretLength)
+		if _, err := rand.Read(s.unlockSecret); err != nil {
+			panic(fmt.Sprintf("could not generate unlock secret: %s", err))
+		}
+	}
+
+	if len(s.visitorSalt) == 0 {
+		s.visitorSalt = make([]byte, visitorSaltLength)
+		if _, err := rand.Read(s.visitorSalt); err != nil {
+			panic(fmt.Sprintf("could not generate visitor salt: %s", err))
+		}
 	}
+	return s
 }
 
-func (s *ServiceDefault) CreateShortURL(ctx context.Context, longURL string) (string, error) {
-	existingShortURL, err := s.repo.GetShortURL(ctx, longURL)
+func (s *ServiceDefault) CreateShortURL(ctx context.Context, in CreateShortURLInput) (string, error) {
+	if err := ValidateLongURL(in.LongURL); err != nil {
+		return "", err
+	}
+
+	redirectStatus := in.RedirectStatus
+	if redirectStatus == 0 {
+		redirectStatus = s.defaultRedirectStatus
+	}
+
+	if !ValidRedirectStatus(redirectStatus) {
+		return "", fmt.Errorf("invalid redirect status %d", redirectStatus)
+	}
+
+	if in.NotBefore != nil && in.NotAfter != nil && !in.NotAfter.After(*in.NotBefore) {
+		return "", fmt.Errorf("not_after must be after not_before")
+	}
+
+	// Links restricted by a password or an activation window, or owned by an
+	// API key, are never shared, so they get their own short URL instead of
+	// reusing an existing one.
+	if in.Password != "" || in.NotBefore != nil || in.NotAfter != nil || in.OwnerKeyID != 0 {
+		url := repository.URL{
+			LongURL:        in.LongURL,
+			RedirectStatus: redirectStatus,
+			NotBefore:      in.NotBefore,
+			NotAfter:       in.NotAfter,
+		}
+
+		if in.OwnerKeyID != 0 {
+			url.OwnerKeyID = &in.OwnerKeyID
+		}
+
+		if in.Password != "" {
+			passwordHash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return "", fmt.Errorf("could not hash password: %w", err)
+			}
+			url.PasswordHash = string(passwordHash)
+		}
+
+		seed, err := uniqueSeed(in.LongURL)
+		if err != nil {
+			return "", err
+		}
+		return s.saveShortURL(ctx, seed, url)
+	}
+
+	// A long URL is shared only between links redirecting with the same
+	// status, so each status gets a short URL of its own.
+	existingShortURL, err := s.repo.GetShortURL(ctx, in.LongURL, redirectStatus)
 	if err != nil {
 		return "", fmt.Errorf("could not get short url: %w", err)
 	}
@@ -36,43 +192,257 @@ func (s *ServiceDefault) CreateShortURL(ctx context.Context, longURL string) (st
 		return existingShortURL, nil
 	}
 
-	shortURL, err := s.generateShortURL(longURL)
+	// Links redirecting with 302, the status every link had before it could
+	// be chosen, keep the long URL as seed so their short URL does not change.
+	seed := in.LongURL
+	if redirectStatus != http.StatusFound {
+		seed = fmt.Sprintf("%s\x00%d", in.LongURL, redirectStatus)
+	}
+
+	return s.saveShortURL(ctx, seed, repository.URL{
+		LongURL:        in.LongURL,
+		RedirectStatus: redirectStatus,
+	})
+}
+
+// saveShortURL saves url under the short URL generated from seed. Generated
+// codes are short, so when one is already taken it is generated again from
+// a random seed, up to maxSaveAttempts times.
+func (s *ServiceDefault) saveShortURL(ctx context.Context, seed string, url repository.URL) (string, error) {
+	for attempt := 1; ; attempt++ {
+		shortURL, err := s.generateShortURL(seed)
+		if err != nil {
+			return "", fmt.Errorf("could not generate short url: %w", err)
+		}
+
+		s.log(ctx).Info("generated short url", zap.String("short_url", shortURL))
+
+		url.ShortURL = shortURL
+
+		err = s.repo.SaveShortURL(ctx, url)
+		if err == nil {
+			return shortURL, nil
+		}
+
+		if !errors.Is(err, repository.ErrShortURLTaken) || attempt == maxSaveAttempts {
+			return "", fmt.Errorf("could not save short url: %w", err)
+		}
+
+		if seed, err = uniqueSeed(url.LongURL); err != nil {
+			return "", err
+		}
+	}
+}
+
+// uniqueSeed returns a seed for a short URL of longURL that is not shared
+// with any other.
+func uniqueSeed(longURL string) (string, error) {
+	seed := make([]byte, uniqueSeedLength)
+	if _, err := rand.Read(seed); err != nil {
+		return "", fmt.Errorf("could not generate seed: %w", err)
+	}
+	return longURL + string(seed), nil
+}
+
+func (s *ServiceDefault) RedirectToLongURL(ctx context.Context, in RedirectInput) (Redirect, error) {
+	url, err := s.repo.GetURL(ctx, in.ShortURL)
 	if err != nil {
-		return "", fmt.Errorf("could not generate short url: %w", err)
+		return Redirect{}, fmt.Errorf("could not get long url: %w", err)
+	}
+
+	if url.LongURL == "" {
+		return Redirect{}, fmt.Errorf("could not find long url for short url %s: %w", in.ShortURL, ErrNotFound)
+	}
+
+	if err := s.checkActive(url); err != nil {
+		return Redirect{}, fmt.Errorf("short url %s is unavailable: %w", in.ShortURL, err)
+	}
+
+	if url.PasswordHash != "" && !s.validUnlockToken(in.ShortURL, in.UnlockToken) {
+		return Redirect{}, fmt.Errorf("short url %s is locked: %w", in.ShortURL, ErrPasswordRequired)
+	}
+
+	target, rulesEvaluated, err := s.resolveTarget(ctx, in)
+	if err != nil {
+		return Redirect{}, fmt.Errorf("could not resolve target: %w", err)
+	}
+
+	redirect := Redirect{
+		LongURL:           target,
+		StatusCode:        url.RedirectStatus,
+		PasswordProtected: url.PasswordHash != "",
+		RulesEvaluated:    rulesEvaluated,
+	}
+	bot := s.botDetector != nil && s.botDetector.IsBot(in.UserAgent, in.ClientIP)
+
+	if !ValidRedirectStatus(redirect.StatusCode) {
+		redirect.StatusCode = s.defaultRedirectStatus
+	}
+
+	if redirect.LongURL == "" {
+		variant, err := s.pickVariant(ctx, url, in)
+		if err != nil {
+			return Redirect{}, fmt.Errorf("could not pick variant: %w", err)
+		}
+
+		if variant != nil {
+			if !bot {
+				if err := s.repo.RecordVariantHit(ctx, variant.ID); err != nil {
+					return Redirect{}, fmt.Errorf("could not record variant hit: %w", err)
+				}
+			}
+
+			redirect.LongURL = variant.TargetURL
+			redirect.VariantID = variant.ID
+			redirect.StickyVariant = url.StickyVariants
+		} else {
+			redirect.LongURL = url.LongURL
+		}
+	}
+
+	if bot {
+		if err := s.repo.RecordBotHit(ctx, in.ShortURL); err != nil {
+			return Redirect{}, fmt.Errorf("could not record bot hit: %w", err)
+		}
+		return redirect, nil
+	}
+
+	if err := s.repo.RecordHit(ctx, s.newHit(in)); err != nil {
+		return Redirect{}, fmt.Errorf("could not record hit: %w", err)
+	}
+
+	// The leaderboard is public, so it leaves out the links that are not
+	// shared, as they would be disclosed by it.
+	if url.PasswordHash == "" && url.NotBefore == nil && url.NotAfter == nil && url.OwnerKeyID == nil {
+		s.leaderboard.Add(in.ShortURL, s.now())
+	}
+
+	if err := s.repo.AddVisitor(ctx, in.ShortURL, s.today(), s.visitorHash(in)); err != nil {
+		return Redirect{}, fmt.Errorf("could not record visitor: %w", err)
+	}
+	return redirect, nil
+}
+
+func (s *ServiceDefault) UnlockURL(ctx context.Context, shortURL, password string) (UnlockToken, error) {
+	url, err := s.repo.GetURL(ctx, shortURL)
+	if err != nil {
+		return UnlockToken{}, fmt.Errorf("could not get url: %w", err)
+	}
+
+	if url.LongURL == "" {
+		return UnlockToken{}, fmt.Errorf("could not find url for short url %s: %w", shortURL, ErrNotFound)
 	}
 
-	s.logger.Info("generated short url", zap.String("short_url", shortURL))
+	if err := s.checkActive(url); err != nil {
+		return UnlockToken{}, fmt.Errorf("short url %s is unavailable: %w", shortURL, err)
+	}
 
-	if err := s.repo.SaveShortURL(ctx, shortURL, longURL); err != nil {
-		return "", fmt.Errorf("could not save short url: %w", err)
+	if url.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return UnlockToken{}, ErrInvalidPassword
+			}
+			return UnlockToken{}, fmt.Errorf("could not compare password: %w", err)
+		}
 	}
-	return shortURL, nil
+	return s.newUnlockToken(shortURL), nil
 }
 
-func (s *ServiceDefault) RedirectToLongURL(ctx context.Context, shortURL string) (string, error) {
-	longURL, err := s.repo.GetLongURL(ctx, shortURL)
+func (s *ServiceDefault) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	url, err := s.repo.GetURL(ctx, shortURL)
 	if err != nil {
-		return "", fmt.Errorf("could not get long url: %w", err)
+		return URL{}, fmt.Errorf("could not get url: %w", err)
+	}
+
+	if url.LongURL == "" {
+		return URL{}, fmt.Errorf("could not find url for short url %s: %w", shortURL, ErrNotFound)
+	}
+
+	result := URL{
+		ShortURL:          url.ShortURL,
+		LongURL:           url.LongURL,
+		RedirectStatus:    url.RedirectStatus,
+		PasswordProtected: url.PasswordHash != "",
+		NotBefore:         url.NotBefore,
+		NotAfter:          url.NotAfter,
+		Active:            s.checkActive(url) == nil,
+		Hits:              url.Hits,
+		CreatedAt:         url.CreatedAt,
 	}
 
-	if longURL == "" {
-		return "", fmt.Errorf("could not find long url for short url %s", shortURL)
+	if url.OwnerKeyID != nil {
+		result.OwnerKeyID = *url.OwnerKeyID
 	}
-	return longURL, nil
+	return result, nil
 }
 
-func (s *ServiceDefault) GetStats(ctx context.Context, shortURL string) (int, error) {
-	stats, err := s.repo.GetStats(ctx, shortURL)
+func (s *ServiceDefault) DeleteURL(ctx context.Context, shortURL string) error {
+	deleted, err := s.repo.DeleteURL(ctx, shortURL)
+	if err != nil {
+		return fmt.Errorf("could not delete url: %w", err)
+	}
+
+	if !deleted {
+		return fmt.Errorf("could not find url for short url %s: %w", shortURL, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *ServiceDefault) GetStats(ctx context.Context, shortURL string, query StatsQuery) (Stats, error) {
+	if err := s.normalizeStatsQuery(&query); err != nil {
+		return Stats{}, err
+	}
+
+	counters, err := s.repo.GetStats(ctx, shortURL)
+	if err != nil {
+		return Stats{}, fmt.Errorf("could not get stats: %w", err)
+	}
+
+	variants, err := s.repo.ListVariants(ctx, shortURL)
 	if err != nil {
-		return 0, fmt.Errorf("could not get stats: %w", err)
+		return Stats{}, fmt.Errorf("could not list variants: %w", err)
+	}
+
+	stats := Stats{Hits: counters.Hits, BotHits: counters.BotHits, Variants: newVariants(variants)}
+	if err := s.countVisitors(ctx, shortURL, &stats); err != nil {
+		return Stats{}, err
+	}
+
+	if err := s.topSources(ctx, shortURL, query, &stats); err != nil {
+		return Stats{}, err
+	}
+
+	if query.Interval != "" {
+		if stats.Series, err = s.hitSeries(ctx, shortURL, query); err != nil {
+			return Stats{}, err
+		}
 	}
 	return stats, nil
 }
 
+// log returns the service logger annotated with the request ID carried by ctx.
+func (s *ServiceDefault) log(ctx context.Context) *zap.Logger {
+	return requestid.Logger(ctx, s.logger)
+}
+
+// checkActive returns ErrNotActive or ErrExpired when url is outside its activation window.
+func (s *ServiceDefault) checkActive(url repository.URL) error {
+	now := s.now()
+
+	if url.NotBefore != nil && now.Before(*url.NotBefore) {
+		return ErrNotActive
+	}
+
+	if url.NotAfter != nil && !now.Before(*url.NotAfter) {
+		return ErrExpired
+	}
+	return nil
+}
+
 func (s *ServiceDefault) generateShortURL(longURL string) (string, error) {
 	h := sha1.New()
 	if _, err := io.WriteString(h, longURL); err != nil {
 		return "", fmt.Errorf("could not generate short url: %w", err)
 	}
-	return s.appHost + fmt.Sprintf("%x", h.Sum(nil))[:6], nil
+	return s.appHost + fmt.Sprintf("%x", h.Sum(nil))[:generatedCodeLength], nil
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/alesr/urltinyizer/internal/repository"
 	"github.com/stretchr/testify/require"
@@ -21,17 +24,17 @@ func TestCreateShortURL(t *testing.T) {
 		expect := "http://bar/7633a1"
 
 		repoMock := &repository.Mock{
-			GetShortURLFunc: func(ctx context.Context, longURL string) (string, error) {
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
 				return "", nil
 			},
-			SaveShortURLFunc: func(ctx context.Context, longURL, shortURL string) error {
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
 				return nil
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		observed, err := svc.CreateShortURL(context.Background(), given)
+		observed, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: given})
 		require.NoError(t, err)
 
 		require.Equal(t, expect, observed)
@@ -44,33 +47,211 @@ func TestCreateShortURL(t *testing.T) {
 		expect := "http://bar/7633a1"
 
 		repoMock := &repository.Mock{
-			GetShortURLFunc: func(ctx context.Context, longURL string) (string, error) {
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
 				return expect, nil
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		observed, err := svc.CreateShortURL(context.Background(), given)
+		observed, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: given})
 		require.NoError(t, err)
 
 		require.Equal(t, expect, observed)
 	})
 
+	t.Run("short url with another redirect status is not shared", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+		repoMock := &repository.Mock{
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+				if redirectStatus == http.StatusFound {
+					return "http://bar/7633a1", nil
+				}
+				return "", nil
+			},
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				saved = url
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:        "https://www.foo.com",
+			RedirectStatus: http.StatusMovedPermanently,
+		})
+		require.NoError(t, err)
+
+		require.NotEqual(t, "http://bar/7633a1", observed)
+		require.Equal(t, observed, saved.ShortURL)
+		require.Equal(t, http.StatusMovedPermanently, saved.RedirectStatus)
+	})
+
+	t.Run("owned short url is not shared", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+		repoMock := &repository.Mock{
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+				return "http://bar/7633a1", nil
+			},
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				saved = url
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:    "https://www.foo.com",
+			OwnerKeyID: 3,
+		})
+		require.NoError(t, err)
+
+		require.NotEqual(t, "http://bar/7633a1", observed)
+		require.NotNil(t, saved.OwnerKeyID)
+		require.Equal(t, int64(3), *saved.OwnerKeyID)
+	})
+
+	t.Run("taken short url is generated again", func(t *testing.T) {
+		t.Parallel()
+
+		var saved []string
+		repoMock := &repository.Mock{
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				saved = append(saved, url.ShortURL)
+				if len(saved) == 1 {
+					return fmt.Errorf("could not save: %w", repository.ErrShortURLTaken)
+				}
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:    "https://www.foo.com",
+			OwnerKeyID: 3,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, saved, 2)
+		require.NotEqual(t, saved[0], saved[1])
+		require.Equal(t, saved[1], observed)
+	})
+
+	t.Run("short url stays taken", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts int
+		repoMock := &repository.Mock{
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+				return "", nil
+			},
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				attempts++
+				return repository.ErrShortURLTaken
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: "https://www.foo.com"})
+		require.ErrorIs(t, err, repository.ErrShortURLTaken)
+
+		require.Equal(t, maxSaveAttempts, attempts)
+	})
+
+	t.Run("create short url with default redirect status", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+
+		repoMock := &repository.Mock{
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+				return "", nil
+			},
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				saved = url
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithDefaultRedirectStatus(http.StatusTemporaryRedirect))
+
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: "https://www.foo.com"})
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusTemporaryRedirect, saved.RedirectStatus)
+	})
+
+	t.Run("create short url with redirect status", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+
+		repoMock := &repository.Mock{
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
+				return "", nil
+			},
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				saved = url
+				return nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:        "https://www.foo.com",
+			RedirectStatus: http.StatusMovedPermanently,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusMovedPermanently, saved.RedirectStatus)
+	})
+
+	t.Run("invalid long url", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{})
+
+		for _, given := range []string{"", "foo", "ftp://www.foo.com", "https://"} {
+			_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: given})
+			require.ErrorIs(t, err, ErrInvalidURL, given)
+		}
+	})
+
+	t.Run("invalid redirect status", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{})
+
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:        "https://www.foo.com",
+			RedirectStatus: http.StatusOK,
+		})
+		require.Error(t, err)
+	})
+
 	t.Run("error getting short url", func(t *testing.T) {
 		t.Parallel()
 
 		given := "https://www.foo.com"
 
 		repoMock := &repository.Mock{
-			GetShortURLFunc: func(ctx context.Context, longURL string) (string, error) {
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
 				return "", fmt.Errorf("error getting short url")
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		_, err := svc.CreateShortURL(context.Background(), given)
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: given})
 		require.Error(t, err)
 	})
 
@@ -80,17 +261,17 @@ func TestCreateShortURL(t *testing.T) {
 		given := "https://www.foo.com"
 
 		repoMock := &repository.Mock{
-			GetShortURLFunc: func(ctx context.Context, longURL string) (string, error) {
+			GetShortURLFunc: func(ctx context.Context, longURL string, redirectStatus int) (string, error) {
 				return "", nil
 			},
-			SaveShortURLFunc: func(ctx context.Context, longURL, shortURL string) error {
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
 				return fmt.Errorf("error saving short url")
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		_, err := svc.CreateShortURL(context.Background(), given)
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{LongURL: given})
 		require.Error(t, err)
 	})
 }
@@ -105,17 +286,63 @@ func TestRedirectToLongURL(t *testing.T) {
 		expect := "https://www.foo.com"
 
 		repoMock := &repository.Mock{
-			GetLongURLFunc: func(ctx context.Context, shortURL string) (string, error) {
-				return expect, nil
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{ShortURL: given, LongURL: expect, RedirectStatus: http.StatusFound}, nil
+			},
+			RecordHitFunc: func(ctx context.Context, hit repository.Hit) error {
+				return nil
+			},
+			AddVisitorFunc: func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+				return nil
+			},
+			ListRulesFunc: func(ctx context.Context, shortURL string) ([]repository.Rule, error) {
+				return nil, nil
+			},
+			ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+				return nil, nil
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		observed, err := svc.RedirectToLongURL(context.Background(), given)
+		observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: given})
 		require.NoError(t, err)
 
-		require.Equal(t, expect, observed)
+		require.Equal(t, expect, observed.LongURL)
+		require.Equal(t, http.StatusFound, observed.StatusCode)
+		require.False(t, observed.RulesEvaluated)
+		require.False(t, observed.PasswordProtected)
+	})
+
+	t.Run("redirect with link status code", func(t *testing.T) {
+		t.Parallel()
+
+		given := "http://bar/7633a1"
+
+		repoMock := &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{ShortURL: given, LongURL: "https://www.foo.com", RedirectStatus: http.StatusPermanentRedirect}, nil
+			},
+			RecordHitFunc: func(ctx context.Context, hit repository.Hit) error {
+				return nil
+			},
+			AddVisitorFunc: func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+				return nil
+			},
+			ListRulesFunc: func(ctx context.Context, shortURL string) ([]repository.Rule, error) {
+				return nil, nil
+			},
+			ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+				return nil, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: given})
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusPermanentRedirect, observed.StatusCode)
 	})
 
 	t.Run("error getting long url", func(t *testing.T) {
@@ -124,14 +351,14 @@ func TestRedirectToLongURL(t *testing.T) {
 		given := "http://bar/7633a1"
 
 		repoMock := &repository.Mock{
-			GetLongURLFunc: func(ctx context.Context, shortURL string) (string, error) {
-				return "", fmt.Errorf("error getting long url")
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{}, fmt.Errorf("error getting long url")
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		_, err := svc.RedirectToLongURL(context.Background(), given)
+		_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: given})
 		require.Error(t, err)
 	})
 
@@ -141,18 +368,354 @@ func TestRedirectToLongURL(t *testing.T) {
 		given := "http://bar/7633a1"
 
 		repoMock := &repository.Mock{
-			GetLongURLFunc: func(ctx context.Context, shortURL string) (string, error) {
-				return "", nil
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{}, nil
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		_, err := svc.RedirectToLongURL(context.Background(), given)
+		_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: given})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestPasswordProtectedURL(t *testing.T) {
+	t.Parallel()
+
+	given := "http://bar/7633a1"
+
+	newRepoMock := func(saved *repository.URL) *repository.Mock {
+		return &repository.Mock{
+			SaveShortURLFunc: func(ctx context.Context, url repository.URL) error {
+				*saved = url
+				return nil
+			},
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return *saved, nil
+			},
+			RecordHitFunc: func(ctx context.Context, hit repository.Hit) error {
+				return nil
+			},
+			AddVisitorFunc: func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+				return nil
+			},
+			ListRulesFunc: func(ctx context.Context, shortURL string) ([]repository.Rule, error) {
+				return nil, nil
+			},
+			ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+				return nil, nil
+			},
+		}
+	}
+
+	t.Run("create protected short url", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepoMock(&saved))
+
+		observed, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:  "https://www.foo.com",
+			Password: "secret",
+		})
+		require.NoError(t, err)
+
+		require.NotEqual(t, given, observed)
+		require.NotEmpty(t, saved.PasswordHash)
+		require.NotEqual(t, "secret", saved.PasswordHash)
+	})
+
+	t.Run("redirect requires unlock token", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepoMock(&saved))
+
+		shortURL, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:  "https://www.foo.com",
+			Password: "secret",
+		})
+		require.NoError(t, err)
+
+		_, err = svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: shortURL})
+		require.ErrorIs(t, err, ErrPasswordRequired)
+
+		_, err = svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: shortURL, UnlockToken: "1.forged"})
+		require.ErrorIs(t, err, ErrPasswordRequired)
+
+		token, err := svc.UnlockURL(context.Background(), shortURL, "secret")
+		require.NoError(t, err)
+
+		observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: shortURL, UnlockToken: token.Value})
+		require.NoError(t, err)
+
+		require.Equal(t, "https://www.foo.com", observed.LongURL)
+		require.True(t, observed.PasswordProtected)
+	})
+
+	t.Run("unlock token is bound to the short url", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepoMock(&saved))
+
+		shortURL, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:  "https://www.foo.com",
+			Password: "secret",
+		})
+		require.NoError(t, err)
+
+		token, err := svc.UnlockURL(context.Background(), shortURL, "secret")
+		require.NoError(t, err)
+
+		require.False(t, svc.validUnlockToken(given, token.Value))
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		t.Parallel()
+
+		var saved repository.URL
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", newRepoMock(&saved))
+
+		shortURL, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:  "https://www.foo.com",
+			Password: "secret",
+		})
+		require.NoError(t, err)
+
+		_, err = svc.UnlockURL(context.Background(), shortURL, "wrong")
+		require.ErrorIs(t, err, ErrInvalidPassword)
+	})
+}
+
+func TestActivationWindow(t *testing.T) {
+	t.Parallel()
+
+	given := "http://bar/7633a1"
+	notBefore := time.Date(2023, 3, 1, 9, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2023, 3, 31, 9, 0, 0, 0, time.UTC)
+
+	repoMock := &repository.Mock{
+		GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+			return repository.URL{
+				ShortURL:       given,
+				LongURL:        "https://www.foo.com",
+				RedirectStatus: http.StatusFound,
+				NotBefore:      &notBefore,
+				NotAfter:       &notAfter,
+			}, nil
+		},
+		RecordHitFunc: func(ctx context.Context, hit repository.Hit) error {
+			return nil
+		},
+		AddVisitorFunc: func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+			return nil
+		},
+		ListRulesFunc: func(ctx context.Context, shortURL string) ([]repository.Rule, error) {
+			return nil, nil
+		},
+		ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+			return nil, nil
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		now         time.Time
+		expectedErr error
+	}{
+		{
+			name:        "before activation",
+			now:         notBefore.Add(-time.Second),
+			expectedErr: ErrNotActive,
+		},
+		{
+			name: "at activation",
+			now:  notBefore,
+		},
+		{
+			name: "within window",
+			now:  notBefore.Add(24 * time.Hour),
+		},
+		{
+			name:        "at deactivation",
+			now:         notAfter,
+			expectedErr: ErrExpired,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time { return tc.now }))
+
+			_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: given})
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("invalid window", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{})
+
+		_, err := svc.CreateShortURL(context.Background(), CreateShortURLInput{
+			LongURL:   "https://www.foo.com",
+			NotBefore: &notAfter,
+			NotAfter:  &notBefore,
+		})
 		require.Error(t, err)
 	})
 }
 
+type botDetectorStub func(userAgent string) bool
+
+func (b botDetectorStub) IsBot(userAgent string, ip net.IP) bool {
+	return b(userAgent)
+}
+
+func TestRedirectBots(t *testing.T) {
+	t.Parallel()
+
+	var hits, botHits, visitors int
+	variantHits := make(map[int64]int)
+
+	repoMock := newVariantsRepoMock(false, variantHits)
+	repoMock.RecordHitFunc = func(ctx context.Context, hit repository.Hit) error {
+		hits++
+		return nil
+	}
+	repoMock.RecordBotHitFunc = func(ctx context.Context, shortURL string) error {
+		botHits++
+		return nil
+	}
+	repoMock.AddVisitorFunc = func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+		visitors++
+		return nil
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithBotDetector(botDetectorStub(func(userAgent string) bool {
+		return userAgent == "Twitterbot/1.0"
+	})))
+
+	for _, userAgent := range []string{"Twitterbot/1.0", "Mozilla/5.0", "Twitterbot/1.0"} {
+		observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+			ShortURL:  "http://bar/7633a1",
+			UserAgent: userAgent,
+		})
+		require.NoError(t, err)
+
+		require.NotEmpty(t, observed.LongURL)
+	}
+
+	require.Equal(t, 1, hits)
+	require.Equal(t, 2, botHits)
+	require.Equal(t, 1, visitors)
+	require.Equal(t, 1, variantHits[1]+variantHits[2])
+}
+
+func TestGetURL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("get url", func(t *testing.T) {
+		t.Parallel()
+
+		given := "http://bar/7633a1"
+		createdAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+		ownerKeyID := int64(3)
+
+		repoMock := &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{
+					ShortURL:       given,
+					LongURL:        "https://www.foo.com",
+					RedirectStatus: http.StatusFound,
+					Hits:           3,
+					CreatedAt:      createdAt,
+					OwnerKeyID:     &ownerKeyID,
+				}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.GetURL(context.Background(), given)
+		require.NoError(t, err)
+
+		require.Equal(t, URL{
+			ShortURL:       given,
+			LongURL:        "https://www.foo.com",
+			RedirectStatus: http.StatusFound,
+			Active:         true,
+			Hits:           3,
+			CreatedAt:      createdAt,
+			OwnerKeyID:     3,
+		}, observed)
+	})
+
+	t.Run("error short url not found", func(t *testing.T) {
+		t.Parallel()
+
+		repoMock := &repository.Mock{
+			GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+				return repository.URL{}, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		_, err := svc.GetURL(context.Background(), "http://bar/7633a1")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestDeleteURL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("delete url", func(t *testing.T) {
+		t.Parallel()
+
+		var deleted string
+		repoMock := &repository.Mock{
+			DeleteURLFunc: func(ctx context.Context, shortURL string) (bool, error) {
+				deleted = shortURL
+				return true, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		require.NoError(t, svc.DeleteURL(context.Background(), "http://bar/7633a1"))
+		require.Equal(t, "http://bar/7633a1", deleted)
+	})
+
+	t.Run("error short url not found", func(t *testing.T) {
+		t.Parallel()
+
+		repoMock := &repository.Mock{
+			DeleteURLFunc: func(ctx context.Context, shortURL string) (bool, error) {
+				return false, nil
+			},
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		err := svc.DeleteURL(context.Background(), "http://bar/7633a1")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
 func TestGetStats(t *testing.T) {
 	t.Parallel()
 
@@ -160,17 +723,40 @@ func TestGetStats(t *testing.T) {
 		t.Parallel()
 
 		given := "http://bar/7633a1"
-		expect := 10
+		expect := Stats{
+			Hits:                10,
+			BotHits:             2,
+			DailyUniqueVisitors: []DailyVisitors{},
+			Referrers:           []SourceCount{},
+			UTMSources:          []SourceCount{},
+			UserAgentFamilies:   []SourceCount{},
+			Variants: []Variant{
+				{ID: 1, TargetURL: "https://www.foo.com/a", Weight: 70, Hits: 7},
+				{ID: 2, TargetURL: "https://www.foo.com/b", Weight: 30, Hits: 3},
+			},
+		}
 
 		repoMock := &repository.Mock{
-			GetStatsFunc: func(ctx context.Context, shortURL string) (int, error) {
-				return 10, nil
+			GetStatsFunc: func(ctx context.Context, shortURL string) (repository.Stats, error) {
+				return repository.Stats{Hits: 10, BotHits: 2}, nil
+			},
+			ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+				return []repository.Variant{
+					{ID: 1, ShortURL: given, TargetURL: "https://www.foo.com/a", Weight: 70, Hits: 7},
+					{ID: 2, ShortURL: given, TargetURL: "https://www.foo.com/b", Weight: 30, Hits: 3},
+				}, nil
+			},
+			ListVisitorSketchesFunc: func(ctx context.Context, shortURL string) ([]repository.VisitorSketch, error) {
+				return nil, nil
+			},
+			TopSourcesFunc: func(ctx context.Context, query repository.TopSourcesQuery) ([]repository.SourceCount, error) {
+				return nil, nil
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		observed, err := svc.GetStats(context.Background(), given)
+		observed, err := svc.GetStats(context.Background(), given, StatsQuery{})
 		require.NoError(t, err)
 
 		require.Equal(t, expect, observed)
@@ -182,14 +768,14 @@ func TestGetStats(t *testing.T) {
 		given := "http://bar/7633a1"
 
 		repoMock := &repository.Mock{
-			GetStatsFunc: func(ctx context.Context, shortURL string) (int, error) {
-				return 0, fmt.Errorf("error getting stats")
+			GetStatsFunc: func(ctx context.Context, shortURL string) (repository.Stats, error) {
+				return repository.Stats{}, fmt.Errorf("error getting stats")
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		_, err := svc.GetStats(context.Background(), given)
+		_, err := svc.GetStats(context.Background(), given, StatsQuery{})
 		require.Error(t, err)
 	})
 
@@ -199,14 +785,14 @@ func TestGetStats(t *testing.T) {
 		given := "http://bar/7633a1"
 
 		repoMock := &repository.Mock{
-			GetStatsFunc: func(ctx context.Context, shortURL string) (int, error) {
-				return 0, errors.New("some error")
+			GetStatsFunc: func(ctx context.Context, shortURL string) (repository.Stats, error) {
+				return repository.Stats{}, errors.New("some error")
 			},
 		}
 
 		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
 
-		_, err := svc.GetStats(context.Background(), given)
+		_, err := svc.GetStats(context.Background(), given, StatsQuery{})
 		require.Error(t, err)
 	})
 }
@@ -1,23 +1,97 @@
 package service
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 var _ Service = (*Mock)(nil)
 
 type Mock struct {
-	CreateShortURLFunc    func(ctx context.Context, longURL string) (string, error)
-	RedirectToLongURLFunc func(ctx context.Context, shortURL string) (string, error)
-	GetStatsFunc          func(ctx context.Context, shortURL string) (int, error)
+	CreateShortURLFunc    func(ctx context.Context, in CreateShortURLInput) (string, error)
+	RedirectToLongURLFunc func(ctx context.Context, in RedirectInput) (Redirect, error)
+	UnlockURLFunc         func(ctx context.Context, shortURL, password string) (UnlockToken, error)
+	GetURLFunc            func(ctx context.Context, shortURL string) (URL, error)
+	GetStatsFunc          func(ctx context.Context, shortURL string, query StatsQuery) (Stats, error)
+	TopLinksFunc          func(ctx context.Context, window time.Duration, limit int) ([]LinkHits, error)
+	AddRuleFunc           func(ctx context.Context, shortURL string, in RuleInput) (Rule, error)
+	ListRulesFunc         func(ctx context.Context, shortURL string) ([]Rule, error)
+	DeleteRuleFunc        func(ctx context.Context, shortURL string, id int64) error
+	SetVariantsFunc       func(ctx context.Context, shortURL string, in VariantSet) (VariantSet, error)
+	GetVariantsFunc       func(ctx context.Context, shortURL string) (VariantSet, error)
+
+	DeleteURLFunc          func(ctx context.Context, shortURL string) error
+	CreateAPIKeyFunc       func(ctx context.Context, name string) (APIKey, error)
+	RevokeAPIKeyFunc       func(ctx context.Context, id int64) error
+	AuthenticateAPIKeyFunc func(ctx context.Context, key string) (APIKey, error)
+	ExportURLsFunc         func(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error
+	ImportURLsFunc         func(ctx context.Context, records []ImportRecord, ownerKeyID int64, dryRun bool) (ImportReport, error)
+}
+
+func (m *Mock) CreateShortURL(ctx context.Context, in CreateShortURLInput) (string, error) {
+	return m.CreateShortURLFunc(ctx, in)
+}
+
+func (m *Mock) RedirectToLongURL(ctx context.Context, in RedirectInput) (Redirect, error) {
+	return m.RedirectToLongURLFunc(ctx, in)
+}
+
+func (m *Mock) UnlockURL(ctx context.Context, shortURL, password string) (UnlockToken, error) {
+	return m.UnlockURLFunc(ctx, shortURL, password)
+}
+
+func (m *Mock) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	return m.GetURLFunc(ctx, shortURL)
+}
+
+func (m *Mock) TopLinks(ctx context.Context, window time.Duration, limit int) ([]LinkHits, error) {
+	return m.TopLinksFunc(ctx, window, limit)
+}
+
+func (m *Mock) GetStats(ctx context.Context, shortURL string, query StatsQuery) (Stats, error) {
+	return m.GetStatsFunc(ctx, shortURL, query)
+}
+
+func (m *Mock) AddRule(ctx context.Context, shortURL string, in RuleInput) (Rule, error) {
+	return m.AddRuleFunc(ctx, shortURL, in)
+}
+
+func (m *Mock) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	return m.ListRulesFunc(ctx, shortURL)
+}
+
+func (m *Mock) DeleteRule(ctx context.Context, shortURL string, id int64) error {
+	return m.DeleteRuleFunc(ctx, shortURL, id)
+}
+
+func (m *Mock) SetVariants(ctx context.Context, shortURL string, in VariantSet) (VariantSet, error) {
+	return m.SetVariantsFunc(ctx, shortURL, in)
+}
+
+func (m *Mock) GetVariants(ctx context.Context, shortURL string) (VariantSet, error) {
+	return m.GetVariantsFunc(ctx, shortURL)
+}
+
+func (m *Mock) DeleteURL(ctx context.Context, shortURL string) error {
+	return m.DeleteURLFunc(ctx, shortURL)
+}
+
+func (m *Mock) CreateAPIKey(ctx context.Context, name string) (APIKey, error) {
+	return m.CreateAPIKeyFunc(ctx, name)
+}
+
+func (m *Mock) RevokeAPIKey(ctx context.Context, id int64) error {
+	return m.RevokeAPIKeyFunc(ctx, id)
 }
 
-func (m *Mock) CreateShortURL(ctx context.Context, longURL string) (string, error) {
-	return m.CreateShortURLFunc(ctx, longURL)
+func (m *Mock) AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error) {
+	return m.AuthenticateAPIKeyFunc(ctx, key)
 }
 
-func (m *Mock) RedirectToLongURL(ctx context.Context, shortURL string) (string, error) {
-	return m.RedirectToLongURLFunc(ctx, shortURL)
+func (m *Mock) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	return m.ExportURLsFunc(ctx, ownerKeyID, fn)
 }
 
-func (m *Mock) GetStats(ctx context.Context, shortURL string) (int, error) {
-	return m.GetStatsFunc(ctx, shortURL)
+func (m *Mock) ImportURLs(ctx context.Context, records []ImportRecord, ownerKeyID int64, dryRun bool) (ImportReport, error) {
+	return m.ImportURLsFunc(ctx, records, ownerKeyID, dryRun)
 }
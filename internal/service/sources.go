@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/alesr/urltinyizer/internal/useragent"
+)
+
+const (
+	defaultSourcesLimit = 10
+	maxSourcesLimit     = 100
+
+	// maxUTMSourceLength bounds the UTM sources stored, since they come from the query string.
+	maxUTMSourceLength = 100
+)
+
+// newHit returns the hit recorded for a redirect request.
+func (s *ServiceDefault) newHit(in RedirectInput) repository.Hit {
+	utmSource := strings.ToLower(strings.TrimSpace(in.UTMSource))
+	if len(utmSource) > maxUTMSourceLength {
+		utmSource = utmSource[:maxUTMSourceLength]
+	}
+
+	return repository.Hit{
+		ShortURL:        in.ShortURL,
+		OccurredAt:      s.now(),
+		ReferrerDomain:  referrerDomain(in.Referrer),
+		UTMSource:       utmSource,
+		UserAgentFamily: useragent.Family(in.UserAgent),
+	}
+}
+
+// referrerDomain returns the host of a Referer header without its www prefix,
+// or an empty string when there is no valid referrer.
+func referrerDomain(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// normalizeStatsQuery validates a stats query and fills in its defaults.
+func (s *ServiceDefault) normalizeStatsQuery(query *StatsQuery) error {
+	if query.To.IsZero() {
+		query.To = s.now()
+	}
+
+	if !query.From.Before(query.To) {
+		return fmt.Errorf("from must be before to: %w", ErrInvalidStatsQuery)
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultSourcesLimit
+	}
+
+	if query.Limit < 0 || query.Limit > maxSourcesLimit {
+		return fmt.Errorf("limit must be between 1 and %d: %w", maxSourcesLimit, ErrInvalidStatsQuery)
+	}
+
+	if query.Offset < 0 {
+		return fmt.Errorf("offset must not be negative: %w", ErrInvalidStatsQuery)
+	}
+
+	if query.Location == nil {
+		query.Location = time.UTC
+	}
+
+	if query.Interval != "" {
+		return validateSeries(*query)
+	}
+	return nil
+}
+
+// topSources fills in the top referrers, UTM sources and browser families of a short URL.
+func (s *ServiceDefault) topSources(ctx context.Context, shortURL string, query StatsQuery, stats *Stats) error {
+	dimensions := []struct {
+		dimension repository.Dimension
+		dest      *[]SourceCount
+	}{
+		{dimension: repository.DimensionReferrer, dest: &stats.Referrers},
+		{dimension: repository.DimensionUTMSource, dest: &stats.UTMSources},
+		{dimension: repository.DimensionUserAgentFamily, dest: &stats.UserAgentFamilies},
+	}
+
+	for _, d := range dimensions {
+		sources, err := s.repo.TopSources(ctx, repository.TopSourcesQuery{
+			ShortURL:  shortURL,
+			Dimension: d.dimension,
+			From:      query.From,
+			To:        query.To,
+			Limit:     query.Limit,
+			Offset:    query.Offset,
+		})
+		if err != nil {
+			return fmt.Errorf("could not get top sources by %s: %w", d.dimension, err)
+		}
+
+		*d.dest = make([]SourceCount, 0, len(sources))
+		for _, source := range sources {
+			*d.dest = append(*d.dest, SourceCount{Value: source.Value, Hits: source.Hits})
+		}
+	}
+	return nil
+}
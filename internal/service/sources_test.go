@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/alesr/urltinyizer/internal/useragent"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRecordHitSources(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	var observed repository.Hit
+
+	repoMock := newRulesRepoMock()
+	repoMock.RecordHitFunc = func(ctx context.Context, hit repository.Hit) error {
+		observed = hit
+		return nil
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time {
+		return now
+	}))
+
+	_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+		ShortURL:  "http://bar/7633a1",
+		UserAgent: iPhoneUA,
+		Referrer:  "https://www.News.example.com/article?id=1",
+		UTMSource: " Newsletter ",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, repository.Hit{
+		ShortURL:        "http://bar/7633a1",
+		OccurredAt:      now,
+		ReferrerDomain:  "news.example.com",
+		UTMSource:       "newsletter",
+		UserAgentFamily: useragent.FamilySafari,
+	}, observed)
+}
+
+func TestReferrerDomain(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		given    string
+		expected string
+	}{
+		{given: "https://www.google.com/", expected: "google.com"},
+		{given: "http://t.co/abc", expected: "t.co"},
+		{given: "android-app://com.slack", expected: ""},
+		{given: "not a url", expected: ""},
+		{given: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.given, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.expected, referrerDomain(tc.given))
+		})
+	}
+}
+
+func TestGetStatsTopSources(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC)
+	from := now.Add(-7 * 24 * time.Hour)
+
+	repoMock := &repository.Mock{
+		GetStatsFunc: func(ctx context.Context, shortURL string) (repository.Stats, error) {
+			return repository.Stats{Hits: 3}, nil
+		},
+		ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+			return nil, nil
+		},
+		ListVisitorSketchesFunc: func(ctx context.Context, shortURL string) ([]repository.VisitorSketch, error) {
+			return nil, nil
+		},
+		TopSourcesFunc: func(ctx context.Context, query repository.TopSourcesQuery) ([]repository.SourceCount, error) {
+			require.Equal(t, from, query.From)
+			require.Equal(t, now, query.To)
+			require.Equal(t, 5, query.Limit)
+			require.Equal(t, 10, query.Offset)
+
+			return []repository.SourceCount{{Value: string(query.Dimension), Hits: 3}}, nil
+		},
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time {
+		return now
+	}))
+
+	t.Run("top sources", func(t *testing.T) {
+		t.Parallel()
+
+		observed, err := svc.GetStats(context.Background(), "http://bar/7633a1", StatsQuery{
+			From:   from,
+			Limit:  5,
+			Offset: 10,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, []SourceCount{{Value: "referrer_domain", Hits: 3}}, observed.Referrers)
+		require.Equal(t, []SourceCount{{Value: "utm_source", Hits: 3}}, observed.UTMSources)
+		require.Equal(t, []SourceCount{{Value: "ua_family", Hits: 3}}, observed.UserAgentFamilies)
+	})
+
+	testCases := []struct {
+		name  string
+		query StatsQuery
+	}{
+		{name: "from after to", query: StatsQuery{From: now, To: from}},
+		{name: "limit too large", query: StatsQuery{Limit: maxSourcesLimit + 1}},
+		{name: "negative offset", query: StatsQuery{Offset: -1}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := svc.GetStats(context.Background(), "http://bar/7633a1", tc.query)
+			require.ErrorIs(t, err, ErrInvalidStatsQuery)
+		})
+	}
+}
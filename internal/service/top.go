@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// The leaderboard counts hits per minute over the last week, in memory.
+	leaderboardResolution = time.Minute
+	leaderboardRetention  = 7 * 24 * time.Hour
+
+	defaultTopLinksLimit = 10
+	maxTopLinksLimit     = 100
+)
+
+// TopLinks returns the short URLs with the most hits in the window ending
+// now, counted by this instance since it started.
+func (s *ServiceDefault) TopLinks(ctx context.Context, window time.Duration, limit int) ([]LinkHits, error) {
+	if window < leaderboardResolution || window > s.leaderboard.Retention() {
+		return nil, fmt.Errorf("window must be between %s and %s: %w", leaderboardResolution, s.leaderboard.Retention(), ErrInvalidStatsQuery)
+	}
+
+	if limit == 0 {
+		limit = defaultTopLinksLimit
+	}
+
+	if limit < 0 || limit > maxTopLinksLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d: %w", maxTopLinksLimit, ErrInvalidStatsQuery)
+	}
+
+	entries := s.leaderboard.Top(s.now(), window, limit)
+
+	links := make([]LinkHits, 0, len(entries))
+	for _, e := range entries {
+		links = append(links, LinkHits{ShortURL: e.Key, Hits: e.Hits})
+	}
+	return links, nil
+}
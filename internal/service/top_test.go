@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTopLinks(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	start := now
+	ownerKeyID := int64(3)
+
+	repoMock := newRulesRepoMock()
+	repoMock.GetURLFunc = func(ctx context.Context, shortURL string) (repository.URL, error) {
+		url := repository.URL{ShortURL: shortURL, LongURL: "https://www.foo.com", RedirectStatus: http.StatusFound}
+		switch shortURL {
+		case "http://bar/owned":
+			url.OwnerKeyID = &ownerKeyID
+		case "http://bar/launch":
+			url.NotBefore = &start
+		}
+		return url, nil
+	}
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time {
+		return now
+	}))
+
+	redirect := func(shortURL string, times int) {
+		for i := 0; i < times; i++ {
+			_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{ShortURL: shortURL})
+			require.NoError(t, err)
+		}
+	}
+
+	redirect("http://bar/aaaaaa", 3)
+	redirect("http://bar/bbbbbb", 5)
+
+	// Links that are not shared are left out.
+	redirect("http://bar/owned", 7)
+	redirect("http://bar/launch", 7)
+
+	// Hits from two hours ago fall out of a one hour window.
+	now = now.Add(2 * time.Hour)
+	redirect("http://bar/cccccc", 1)
+
+	observed, err := svc.TopLinks(context.Background(), 24*time.Hour, 2)
+	require.NoError(t, err)
+
+	require.Equal(t, []LinkHits{
+		{ShortURL: "http://bar/bbbbbb", Hits: 5},
+		{ShortURL: "http://bar/aaaaaa", Hits: 3},
+	}, observed)
+
+	observed, err = svc.TopLinks(context.Background(), time.Hour, 0)
+	require.NoError(t, err)
+
+	require.Equal(t, []LinkHits{{ShortURL: "http://bar/cccccc", Hits: 1}}, observed)
+
+	_, err = svc.TopLinks(context.Background(), 30*24*time.Hour, 10)
+	require.ErrorIs(t, err, ErrInvalidStatsQuery)
+
+	_, err = svc.TopLinks(context.Background(), time.Hour, maxTopLinksLimit+1)
+	require.ErrorIs(t, err, ErrInvalidStatsQuery)
+}
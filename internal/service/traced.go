@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ Service = (*Traced)(nil)
+
+// tracerName is the instrumentation name of the service spans.
+const tracerName = "github.com/alesr/urltinyizer/internal/service"
+
+// Traced is a service recording a span for each operation of another.
+type Traced struct {
+	service Service
+	tracer  trace.Tracer
+}
+
+// NewTraced wraps service with spans from the global tracer provider.
+func NewTraced(service Service) *Traced {
+	return &Traced{service: service, tracer: otel.Tracer(tracerName)}
+}
+
+func (t *Traced) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "service."+method)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *Traced) CreateShortURL(ctx context.Context, in CreateShortURLInput) (string, error) {
+	ctx, span := t.start(ctx, "CreateShortURL")
+	result, err := t.service.CreateShortURL(ctx, in)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) RedirectToLongURL(ctx context.Context, in RedirectInput) (Redirect, error) {
+	ctx, span := t.start(ctx, "RedirectToLongURL")
+	result, err := t.service.RedirectToLongURL(ctx, in)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) UnlockURL(ctx context.Context, shortURL, password string) (UnlockToken, error) {
+	ctx, span := t.start(ctx, "UnlockURL")
+	result, err := t.service.UnlockURL(ctx, shortURL, password)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) GetURL(ctx context.Context, shortURL string) (URL, error) {
+	ctx, span := t.start(ctx, "GetURL")
+	result, err := t.service.GetURL(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) TopLinks(ctx context.Context, window time.Duration, limit int) ([]LinkHits, error) {
+	ctx, span := t.start(ctx, "TopLinks")
+	result, err := t.service.TopLinks(ctx, window, limit)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) GetStats(ctx context.Context, shortURL string, query StatsQuery) (Stats, error) {
+	ctx, span := t.start(ctx, "GetStats")
+	result, err := t.service.GetStats(ctx, shortURL, query)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) AddRule(ctx context.Context, shortURL string, in RuleInput) (Rule, error) {
+	ctx, span := t.start(ctx, "AddRule")
+	result, err := t.service.AddRule(ctx, shortURL, in)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) ListRules(ctx context.Context, shortURL string) ([]Rule, error) {
+	ctx, span := t.start(ctx, "ListRules")
+	result, err := t.service.ListRules(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) DeleteRule(ctx context.Context, shortURL string, id int64) error {
+	ctx, span := t.start(ctx, "DeleteRule")
+	err := t.service.DeleteRule(ctx, shortURL, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) SetVariants(ctx context.Context, shortURL string, in VariantSet) (VariantSet, error) {
+	ctx, span := t.start(ctx, "SetVariants")
+	result, err := t.service.SetVariants(ctx, shortURL, in)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) GetVariants(ctx context.Context, shortURL string) (VariantSet, error) {
+	ctx, span := t.start(ctx, "GetVariants")
+	result, err := t.service.GetVariants(ctx, shortURL)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) DeleteURL(ctx context.Context, shortURL string) error {
+	ctx, span := t.start(ctx, "DeleteURL")
+	err := t.service.DeleteURL(ctx, shortURL)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) CreateAPIKey(ctx context.Context, name string) (APIKey, error) {
+	ctx, span := t.start(ctx, "CreateAPIKey")
+	result, err := t.service.CreateAPIKey(ctx, name)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, span := t.start(ctx, "RevokeAPIKey")
+	err := t.service.RevokeAPIKey(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error) {
+	ctx, span := t.start(ctx, "AuthenticateAPIKey")
+	result, err := t.service.AuthenticateAPIKey(ctx, key)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *Traced) ExportURLs(ctx context.Context, ownerKeyID int64, fn func(ExportedURL) error) error {
+	ctx, span := t.start(ctx, "ExportURLs")
+	err := t.service.ExportURLs(ctx, ownerKeyID, fn)
+	endSpan(span, err)
+	return err
+}
+
+func (t *Traced) ImportURLs(ctx context.Context, records []ImportRecord, ownerKeyID int64, dryRun bool) (ImportReport, error) {
+	ctx, span := t.start(ctx, "ImportURLs")
+	result, err := t.service.ImportURLs(ctx, records, ownerKeyID, dryRun)
+	endSpan(span, err)
+	return result, err
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newUnlockToken returns a token proving that shortURL was unlocked,
+// signed with the service secret and valid for the unlock TTL.
+func (s *ServiceDefault) newUnlockToken(shortURL string) UnlockToken {
+	expiresAt := s.now().Add(s.unlockTTL).Truncate(time.Second)
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return UnlockToken{
+		Value:     expiry + "." + s.signUnlock(shortURL, expiry),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// validUnlockToken reports whether token unlocks shortURL and has not expired.
+func (s *ServiceDefault) validUnlockToken(shortURL, token string) bool {
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || s.now().Unix() >= expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.signUnlock(shortURL, expiry)))
+}
+
+func (s *ServiceDefault) signUnlock(shortURL, expiry string) string {
+	mac := hmac.New(sha256.New, s.unlockSecret)
+	mac.Write([]byte(shortURL + "|" + expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+)
+
+// maxVariants is the maximum number of variants a short URL can have.
+const maxVariants = 100
+
+func (s *ServiceDefault) SetVariants(ctx context.Context, shortURL string, in VariantSet) (VariantSet, error) {
+	if len(in.Variants) > maxVariants {
+		return VariantSet{}, fmt.Errorf("at most %d variants are allowed: %w", maxVariants, ErrInvalidVariant)
+	}
+
+	// Variants are identified by their target URL, which keeps their hits
+	// and sticky visitors across updates.
+	targets := make(map[string]bool, len(in.Variants))
+
+	variants := make([]repository.Variant, 0, len(in.Variants))
+	for _, v := range in.Variants {
+		if v.Weight <= 0 {
+			return VariantSet{}, fmt.Errorf("weight of %s must be positive: %w", v.TargetURL, ErrInvalidVariant)
+		}
+
+		if targets[v.TargetURL] {
+			return VariantSet{}, fmt.Errorf("target url %s is repeated: %w", v.TargetURL, ErrInvalidVariant)
+		}
+		targets[v.TargetURL] = true
+
+		variants = append(variants, repository.Variant{TargetURL: v.TargetURL, Weight: v.Weight})
+	}
+
+	url, err := s.repo.GetURL(ctx, shortURL)
+	if err != nil {
+		return VariantSet{}, fmt.Errorf("could not get url: %w", err)
+	}
+
+	if url.LongURL == "" {
+		return VariantSet{}, fmt.Errorf("could not find url for short url %s: %w", shortURL, ErrNotFound)
+	}
+
+	saved, err := s.repo.ReplaceVariants(ctx, shortURL, in.Sticky, variants)
+	if err != nil {
+		return VariantSet{}, fmt.Errorf("could not save variants: %w", err)
+	}
+	return VariantSet{Sticky: in.Sticky, Variants: newVariants(saved)}, nil
+}
+
+func (s *ServiceDefault) GetVariants(ctx context.Context, shortURL string) (VariantSet, error) {
+	url, err := s.repo.GetURL(ctx, shortURL)
+	if err != nil {
+		return VariantSet{}, fmt.Errorf("could not get url: %w", err)
+	}
+
+	if url.LongURL == "" {
+		return VariantSet{}, fmt.Errorf("could not find url for short url %s: %w", shortURL, ErrNotFound)
+	}
+
+	variants, err := s.repo.ListVariants(ctx, shortURL)
+	if err != nil {
+		return VariantSet{}, fmt.Errorf("could not list variants: %w", err)
+	}
+	return VariantSet{Sticky: url.StickyVariants, Variants: newVariants(variants)}, nil
+}
+
+// pickVariant chooses one of the variants of url by weight. A sticky visitor
+// keeps the variant it was previously given while that variant still exists.
+// It returns nil when the short URL has no variants.
+func (s *ServiceDefault) pickVariant(ctx context.Context, url repository.URL, in RedirectInput) (*repository.Variant, error) {
+	variants, err := s.repo.ListVariants(ctx, url.ShortURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not list variants: %w", err)
+	}
+
+	if len(variants) == 0 {
+		return nil, nil
+	}
+
+	if url.StickyVariants && in.VariantID != 0 {
+		for i := range variants {
+			if variants[i].ID == in.VariantID {
+				return &variants[i], nil
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range variants {
+		total += v.Weight
+	}
+
+	n := s.randIntn(total)
+	for i := range variants {
+		n -= variants[i].Weight
+		if n < 0 {
+			return &variants[i], nil
+		}
+	}
+	return &variants[len(variants)-1], nil
+}
+
+func newVariants(variants []repository.Variant) []Variant {
+	result := make([]Variant, 0, len(variants))
+	for _, v := range variants {
+		result = append(result, Variant{
+			ID:        v.ID,
+			TargetURL: v.TargetURL,
+			Weight:    v.Weight,
+			Hits:      v.Hits,
+		})
+	}
+	return result
+}
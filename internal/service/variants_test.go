@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newVariantsRepoMock(sticky bool, hits map[int64]int) *repository.Mock {
+	return &repository.Mock{
+		GetURLFunc: func(ctx context.Context, shortURL string) (repository.URL, error) {
+			return repository.URL{
+				ShortURL:       shortURL,
+				LongURL:        "https://www.foo.com",
+				RedirectStatus: http.StatusFound,
+				StickyVariants: sticky,
+			}, nil
+		},
+		RecordHitFunc: func(ctx context.Context, hit repository.Hit) error {
+			return nil
+		},
+		AddVisitorFunc: func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+			return nil
+		},
+		ListRulesFunc: func(ctx context.Context, shortURL string) ([]repository.Rule, error) {
+			return nil, nil
+		},
+		ListVariantsFunc: func(ctx context.Context, shortURL string) ([]repository.Variant, error) {
+			return []repository.Variant{
+				{ID: 1, ShortURL: shortURL, TargetURL: "https://www.foo.com/a", Weight: 70},
+				{ID: 2, ShortURL: shortURL, TargetURL: "https://www.foo.com/b", Weight: 30},
+			}, nil
+		},
+		RecordVariantHitFunc: func(ctx context.Context, id int64) error {
+			hits[id]++
+			return nil
+		},
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		roll      int
+		sticky    bool
+		variantID int64
+		expected  string
+	}{
+		{name: "first variant", roll: 0, expected: "https://www.foo.com/a"},
+		{name: "last roll of first variant", roll: 69, expected: "https://www.foo.com/a"},
+		{name: "second variant", roll: 70, expected: "https://www.foo.com/b"},
+		{name: "sticky visitor keeps variant", roll: 0, sticky: true, variantID: 2, expected: "https://www.foo.com/b"},
+		{name: "sticky visitor with stale variant", roll: 0, sticky: true, variantID: 9, expected: "https://www.foo.com/a"},
+		{name: "cookie ignored when not sticky", roll: 0, variantID: 2, expected: "https://www.foo.com/a"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			hits := make(map[int64]int)
+
+			svc := NewServiceDefault(zap.NewNop(), "http://bar/", newVariantsRepoMock(tc.sticky, hits))
+			svc.randIntn = func(n int) int {
+				require.Equal(t, 100, n)
+				return tc.roll
+			}
+
+			observed, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+				ShortURL:  "http://bar/7633a1",
+				VariantID: tc.variantID,
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, observed.LongURL)
+			require.Equal(t, tc.sticky, observed.StickyVariant)
+			require.Equal(t, 1, hits[observed.VariantID])
+		})
+	}
+}
+
+func TestSetVariants(t *testing.T) {
+	t.Parallel()
+
+	t.Run("set variants", func(t *testing.T) {
+		t.Parallel()
+
+		repoMock := newVariantsRepoMock(false, nil)
+		repoMock.ReplaceVariantsFunc = func(ctx context.Context, shortURL string, sticky bool, variants []repository.Variant) ([]repository.Variant, error) {
+			for i := range variants {
+				variants[i].ID = int64(i + 1)
+			}
+			return variants, nil
+		}
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock)
+
+		observed, err := svc.SetVariants(context.Background(), "http://bar/7633a1", VariantSet{
+			Sticky: true,
+			Variants: []Variant{
+				{TargetURL: "https://www.foo.com/a", Weight: 70},
+				{TargetURL: "https://www.foo.com/b", Weight: 30},
+			},
+		})
+		require.NoError(t, err)
+
+		require.True(t, observed.Sticky)
+		require.Len(t, observed.Variants, 2)
+		require.Equal(t, int64(2), observed.Variants[1].ID)
+	})
+
+	t.Run("invalid weight", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{})
+
+		_, err := svc.SetVariants(context.Background(), "http://bar/7633a1", VariantSet{
+			Variants: []Variant{{TargetURL: "https://www.foo.com/a", Weight: 0}},
+		})
+		require.ErrorIs(t, err, ErrInvalidVariant)
+	})
+
+	t.Run("repeated target url", func(t *testing.T) {
+		t.Parallel()
+
+		svc := NewServiceDefault(zap.NewNop(), "http://bar/", &repository.Mock{})
+
+		_, err := svc.SetVariants(context.Background(), "http://bar/7633a1", VariantSet{
+			Variants: []Variant{
+				{TargetURL: "https://www.foo.com/a", Weight: 70},
+				{TargetURL: "https://www.foo.com/a", Weight: 30},
+			},
+		})
+		require.ErrorIs(t, err, ErrInvalidVariant)
+	})
+}
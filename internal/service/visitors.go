@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/hll"
+)
+
+// visitorHash identifies a visitor by their IP address and User-Agent.
+// The salt keeps the hashes from being reversed into IP addresses.
+func (s *ServiceDefault) visitorHash(in RedirectInput) uint64 {
+	mac := hmac.New(sha256.New, s.visitorSalt)
+	mac.Write(in.ClientIP)
+	mac.Write([]byte{0})
+	mac.Write([]byte(in.UserAgent))
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// today returns the current UTC day, which visitors are counted by.
+func (s *ServiceDefault) today() time.Time {
+	return s.now().UTC().Truncate(24 * time.Hour)
+}
+
+// countVisitors fills in the daily and all time unique visitors of a short URL.
+func (s *ServiceDefault) countVisitors(ctx context.Context, shortURL string, stats *Stats) error {
+	sketches, err := s.repo.ListVisitorSketches(ctx, shortURL)
+	if err != nil {
+		return fmt.Errorf("could not list visitor sketches: %w", err)
+	}
+
+	total := hll.New()
+	stats.DailyUniqueVisitors = make([]DailyVisitors, 0, len(sketches))
+
+	for _, sketch := range sketches {
+		daily := hll.New()
+		if err := daily.UnmarshalBinary(sketch.Sketch); err != nil {
+			return fmt.Errorf("could not decode visitor sketch of %s: %w", sketch.Day.Format(time.DateOnly), err)
+		}
+
+		stats.DailyUniqueVisitors = append(stats.DailyUniqueVisitors, DailyVisitors{
+			Day:            sketch.Day,
+			UniqueVisitors: int(daily.Count()),
+		})
+		total.Merge(daily)
+	}
+
+	stats.UniqueVisitors = int(total.Count())
+	return nil
+}
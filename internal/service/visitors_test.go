@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alesr/urltinyizer/internal/hll"
+	"github.com/alesr/urltinyizer/internal/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestUniqueVisitors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		sketches = make(map[time.Time]*hll.Sketch)
+	)
+
+	repoMock := newRulesRepoMock()
+	repoMock.AddVisitorFunc = func(ctx context.Context, shortURL string, day time.Time, hash uint64) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if sketches[day] == nil {
+			sketches[day] = hll.New()
+		}
+		sketches[day].Add(hash)
+		return nil
+	}
+	repoMock.GetStatsFunc = func(ctx context.Context, shortURL string) (repository.Stats, error) {
+		return repository.Stats{Hits: 5}, nil
+	}
+	repoMock.ListVisitorSketchesFunc = func(ctx context.Context, shortURL string) ([]repository.VisitorSketch, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var result []repository.VisitorSketch
+		for day, sketch := range sketches {
+			data, err := sketch.MarshalBinary()
+			require.NoError(t, err)
+
+			result = append(result, repository.VisitorSketch{Day: day, Sketch: data})
+		}
+
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Day.Before(result[j].Day)
+		})
+		return result, nil
+	}
+
+	repoMock.TopSourcesFunc = func(ctx context.Context, query repository.TopSourcesQuery) ([]repository.SourceCount, error) {
+		return nil, nil
+	}
+
+	now := time.Date(2023, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	svc := NewServiceDefault(zap.NewNop(), "http://bar/", repoMock, WithClock(func() time.Time {
+		return now
+	}))
+
+	visits := []struct {
+		ip        string
+		userAgent string
+	}{
+		{ip: "192.0.2.1", userAgent: iPhoneUA},
+		{ip: "192.0.2.1", userAgent: iPhoneUA},
+		{ip: "192.0.2.1", userAgent: windowsUA},
+		{ip: "192.0.2.2", userAgent: iPhoneUA},
+	}
+
+	for _, visit := range visits {
+		_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+			ShortURL:  "http://bar/7633a1",
+			UserAgent: visit.userAgent,
+			ClientIP:  net.ParseIP(visit.ip),
+		})
+		require.NoError(t, err)
+	}
+
+	// The next day the first visitor comes back.
+	now = now.Add(24 * time.Hour)
+
+	_, err := svc.RedirectToLongURL(context.Background(), RedirectInput{
+		ShortURL:  "http://bar/7633a1",
+		UserAgent: iPhoneUA,
+		ClientIP:  net.ParseIP("192.0.2.1"),
+	})
+	require.NoError(t, err)
+
+	observed, err := svc.GetStats(context.Background(), "http://bar/7633a1", StatsQuery{})
+	require.NoError(t, err)
+
+	require.Equal(t, 5, observed.Hits)
+	require.Equal(t, 3, observed.UniqueVisitors)
+	require.Equal(t, []DailyVisitors{
+		{Day: time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC), UniqueVisitors: 3},
+		{Day: time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC), UniqueVisitors: 1},
+	}, observed.DailyUniqueVisitors)
+}
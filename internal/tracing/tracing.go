@@ -0,0 +1,108 @@
+// Package tracing configures OpenTelemetry trace export and W3C trace
+// context propagation.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Exporters traces can be sent to.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// serviceName identifies the application in exported traces.
+const serviceName = "urltinyizer"
+
+// ErrUnknownExporter is returned for an exporter other than the supported ones.
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Config selects where traces are exported to.
+type Config struct {
+	// Exporter is one of the Exporter constants. Empty disables tracing.
+	Exporter string
+	// File is the path traces are appended to with ExporterFile.
+	File string
+	// SampleRatio is the fraction of new traces that are sampled. Traces
+	// started by a sampled upstream are always sampled.
+	SampleRatio float64
+}
+
+// Setup installs the global W3C trace context propagator and, unless the
+// exporter is disabled, a global tracer provider exporting to it. The
+// returned function flushes pending spans and releases the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		// The endpoint and headers are read from the standard
+		// OTEL_EXPORTER_OTLP_* environment variables.
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		var f *os.File
+		if f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, fmt.Errorf("could not open trace file: %w", err)
+		}
+		closer = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("%q: %w", cfg.Exporter, ErrUnknownExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("could not create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
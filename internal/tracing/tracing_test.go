@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("file exporter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.jsonl")
+
+		shutdown, err := Setup(ctx, Config{Exporter: ExporterFile, File: path, SampleRatio: 1})
+		require.NoError(t, err)
+
+		// A remote parent sent in a traceparent header is continued.
+		carrier := propagation.HeaderCarrier{}
+		carrier.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		parentCtx := otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+		_, span := otel.Tracer("test").Start(parentCtx, "test-span")
+		span.End()
+
+		require.NoError(t, shutdown(ctx))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(data), `"Name":"test-span"`)
+		assert.Contains(t, string(data), "4bf92f3577b34da6a3ce929d0e0e4736")
+		assert.Contains(t, string(data), "urltinyizer")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		shutdown, err := Setup(ctx, Config{Exporter: ExporterNone})
+		require.NoError(t, err)
+
+		require.NoError(t, shutdown(ctx))
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(ctx, Config{Exporter: "jaeger"})
+		require.ErrorIs(t, err, ErrUnknownExporter)
+	})
+}
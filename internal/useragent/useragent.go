@@ -0,0 +1,87 @@
+// Package useragent classifies HTTP clients from their User-Agent header.
+package useragent
+
+import "strings"
+
+// Platforms a client can be classified as.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+	PlatformUnknown = "unknown"
+)
+
+// Info describes a client identified from its User-Agent header.
+type Info struct {
+	Platform string
+	Mobile   bool
+}
+
+// Desktop reports whether the client is a known non-mobile platform.
+func (i Info) Desktop() bool {
+	return !i.Mobile && i.Platform != PlatformUnknown
+}
+
+// Parse classifies a User-Agent header.
+func Parse(ua string) Info {
+	s := strings.ToLower(ua)
+
+	switch {
+	case strings.Contains(s, "iphone"), strings.Contains(s, "ipad"), strings.Contains(s, "ipod"):
+		return Info{Platform: PlatformIOS, Mobile: true}
+	case strings.Contains(s, "android"):
+		return Info{Platform: PlatformAndroid, Mobile: true}
+	case strings.Contains(s, "windows phone"):
+		return Info{Platform: PlatformWindows, Mobile: true}
+	case strings.Contains(s, "windows"):
+		return Info{Platform: PlatformWindows}
+	case strings.Contains(s, "macintosh"), strings.Contains(s, "mac os x"):
+		// iPadOS 13+ reports itself as macOS, so only the "Mobile/" token
+		// tells it apart from a desktop Safari.
+		if strings.Contains(s, "mobile/") {
+			return Info{Platform: PlatformIOS, Mobile: true}
+		}
+		return Info{Platform: PlatformMacOS}
+	case strings.Contains(s, "cros"), strings.Contains(s, "linux"), strings.Contains(s, "x11"):
+		return Info{Platform: PlatformLinux}
+	}
+	return Info{Platform: PlatformUnknown, Mobile: strings.Contains(s, "mobile")}
+}
+
+// Browser families a client can be classified as.
+const (
+	FamilyChrome           = "Chrome"
+	FamilyEdge             = "Edge"
+	FamilyFirefox          = "Firefox"
+	FamilyInternetExplorer = "Internet Explorer"
+	FamilyOpera            = "Opera"
+	FamilySafari           = "Safari"
+	FamilySamsungInternet  = "Samsung Internet"
+	FamilyOther            = "Other"
+)
+
+// Family returns the browser family of a User-Agent header. The checks are
+// ordered because most browsers also mention the engines they derive from.
+func Family(ua string) string {
+	s := strings.ToLower(ua)
+
+	switch {
+	case strings.Contains(s, "edg/"), strings.Contains(s, "edge/"), strings.Contains(s, "edgios/"), strings.Contains(s, "edga/"):
+		return FamilyEdge
+	case strings.Contains(s, "opr/"), strings.Contains(s, "opera"):
+		return FamilyOpera
+	case strings.Contains(s, "samsungbrowser/"):
+		return FamilySamsungInternet
+	case strings.Contains(s, "firefox/"), strings.Contains(s, "fxios/"):
+		return FamilyFirefox
+	case strings.Contains(s, "chrome/"), strings.Contains(s, "crios/"), strings.Contains(s, "chromium/"):
+		return FamilyChrome
+	case strings.Contains(s, "safari/"):
+		return FamilySafari
+	case strings.Contains(s, "msie "), strings.Contains(s, "trident/"):
+		return FamilyInternetExplorer
+	}
+	return FamilyOther
+}
@@ -0,0 +1,122 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		given    string
+		expected Info
+	}{
+		{
+			name:     "iphone",
+			given:    "Mozilla/5.0 (iPhone; CPU iPhone OS 16_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.3 Mobile/15E148 Safari/604.1",
+			expected: Info{Platform: PlatformIOS, Mobile: true},
+		},
+		{
+			name:     "ipad desktop mode",
+			given:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.3 Mobile/15E148 Safari/604.1",
+			expected: Info{Platform: PlatformIOS, Mobile: true},
+		},
+		{
+			name:     "android",
+			given:    "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Mobile Safari/537.36",
+			expected: Info{Platform: PlatformAndroid, Mobile: true},
+		},
+		{
+			name:     "windows",
+			given:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			expected: Info{Platform: PlatformWindows},
+		},
+		{
+			name:     "macos",
+			given:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.3 Safari/605.1.15",
+			expected: Info{Platform: PlatformMacOS},
+		},
+		{
+			name:     "linux",
+			given:    "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/109.0",
+			expected: Info{Platform: PlatformLinux},
+		},
+		{
+			name:     "unknown",
+			given:    "curl/7.87.0",
+			expected: Info{Platform: PlatformUnknown},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Parse(tc.given))
+		})
+	}
+}
+
+func TestFamily(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		given    string
+		expected string
+	}{
+		{
+			name:     "chrome",
+			given:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			expected: FamilyChrome,
+		},
+		{
+			name:     "chrome on ios",
+			given:    "Mozilla/5.0 (iPhone; CPU iPhone OS 16_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/109.0.5414.112 Mobile/15E148 Safari/604.1",
+			expected: FamilyChrome,
+		},
+		{
+			name:     "edge",
+			given:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36 Edg/109.0.1518.78",
+			expected: FamilyEdge,
+		},
+		{
+			name:     "firefox",
+			given:    "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/110.0",
+			expected: FamilyFirefox,
+		},
+		{
+			name:     "safari",
+			given:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.3 Safari/605.1.15",
+			expected: FamilySafari,
+		},
+		{
+			name:     "samsung internet",
+			given:    "Mozilla/5.0 (Linux; Android 13; SM-S901B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/20.0 Chrome/106.0.5249.126 Mobile Safari/537.36",
+			expected: FamilySamsungInternet,
+		},
+		{
+			name:     "opera",
+			given:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36 OPR/95.0.0.0",
+			expected: FamilyOpera,
+		},
+		{
+			name:     "other",
+			given:    "curl/7.88.1",
+			expected: FamilyOther,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Family(tc.given))
+		})
+	}
+}
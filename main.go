@@ -3,19 +3,32 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	// Embedded so schedule rules can load IANA time zones on images without tzdata.
+	_ "time/tzdata"
 
 	"go.uber.org/zap"
 
 	"github.com/alesr/urltinyizer/app"
+	"github.com/alesr/urltinyizer/internal/botdetect"
+	"github.com/alesr/urltinyizer/internal/certreload"
+	"github.com/alesr/urltinyizer/internal/geoip"
+	"github.com/alesr/urltinyizer/internal/health"
+	"github.com/alesr/urltinyizer/internal/metrics"
 	"github.com/alesr/urltinyizer/internal/repository"
 	"github.com/alesr/urltinyizer/internal/service"
+	"github.com/alesr/urltinyizer/internal/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
-	envars "github.com/netflix/go-env"
 	"github.com/pressly/goose/v3"
 )
 
@@ -27,23 +40,6 @@ const (
 	dbMigrationsDir    string = "migrations"
 )
 
-type config struct {
-	AppHost string `env:"APP_HOST,default=http://localhost:8080/"`
-	DBUser  string `env:"POSTGRES_USER,default=user"`
-	DBPass  string `env:"POSTGRES_PASSWORD,default=password"`
-	DBName  string `env:"POSTGRES_DB,default=urltinyizer"`
-	DBHost  string `env:"POSTGRES_HOST,default=db"`
-	DBPort  string `env:"POSTGRES_PORT,default=5432"`
-}
-
-func newConfig() *config {
-	var cfg config
-	if _, err := envars.UnmarshalFromEnviron(&cfg); err != nil {
-		log.Fatal(err)
-	}
-	return &cfg
-}
-
 func main() {
 	logger, err := zap.NewProduction()
 	if err != nil {
@@ -51,32 +47,151 @@ func main() {
 	}
 	defer logger.Sync()
 
-	cfg := newConfig()
+	cfg, args := newConfig()
 
-	db, err := sqlx.Open(postgresDriverName, fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName),
-	)
+	command := commandServe
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	if command == commandServe {
+		serve(logger, cfg)
+		return
+	}
+
+	// Commands print their results, so only problems are logged.
+	logger = logger.WithOptions(zap.IncreaseLevel(zap.WarnLevel))
+
+	if err := runCommand(context.Background(), logger, cfg, command, args, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// serve runs the API and admin servers until the process is signalled to stop.
+func serve(logger *zap.Logger, cfg *config) {
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.TracesExporter,
+		File:        cfg.TracesFile,
+		SampleRatio: cfg.TracesSampleRatio,
+	})
+	if err != nil {
+		logger.Fatal("failed to set up tracing", zap.Error(err))
+	}
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("failed to flush traces", zap.Error(err))
+		}
+	}()
+
+	db, err := openDB(cfg)
 	if err != nil {
 		logger.Fatal("failed to connect to database", zap.Error(err))
 	}
 
 	defer db.Close()
 
-	goose.SetBaseFS(embedMigrations)
+	if err := setupMigrations(); err != nil {
+		logger.Fatal("failed to set up goose migrations", zap.Error(err))
+	}
+
+	latestMigration, err := latestMigrationVersion()
+	if err != nil {
+		logger.Fatal("failed to read goose migrations", zap.Error(err))
+	}
+
+	if cfg.AutoMigrate {
+		if err := withMigrationLock(context.Background(), db, func() error {
+			return goose.Up(db.DB, dbMigrationsDir)
+		}); err != nil {
+			logger.Fatal("failed to run goose migrations", zap.Error(err))
+		}
+	} else if err := checkSchema(db.DB, latestMigration); err != nil {
+		logger.Fatal("database schema is outdated, run the migrate up command or enable AUTO_MIGRATE", zap.Error(err))
+	}
+
+	checker := health.New()
+	checker.Add("postgres", db.PingContext)
+	checker.Add("migrations", func(ctx context.Context) error {
+		return checkSchema(db.DB, latestMigration)
+	})
 
-	if err := goose.SetDialect(postgresDriverName); err != nil {
-		logger.Fatal("failed to set goose dialect", zap.Error(err))
+	metrics := metrics.New()
+	if err := metrics.RegisterDB(db.DB, cfg.DBName); err != nil {
+		logger.Fatal("failed to register database metrics", zap.Error(err))
 	}
 
-	if err := goose.Up(db.DB, dbMigrationsDir); err != nil {
-		logger.Fatal("failed to run goose migrations", zap.Error(err))
+	repo := repository.NewInstrumented(
+		repository.NewTraced(repository.NewPostgreSQL(logger, db)),
+		metrics.ObserveQuery,
+	)
+
+	serviceDefault, closeService, err := newService(logger, cfg, repo)
+	if err != nil {
+		logger.Fatal("failed to create service", zap.Error(err))
+	}
+
+	defer closeService()
+
+	// Unlock cookies are signed with the secret, so one that changes on
+	// restart, or differs between instances, asks for the password again.
+	if cfg.UnlockSecret == "" {
+		logger.Warn("UNLOCK_SECRET is not set, unlocked links ask for their password again after a restart and on every other instance; set it to the same value on all instances")
 	}
 
-	repo := repository.NewPostgreSQL(logger, db)
-	service := service.NewServiceDefault(logger, cfg.AppHost, repo)
+	// Visitor sketches are stored, so a salt that changes on restart, or
+	// differs between instances, counts every returning visitor again.
+	if cfg.VisitorSalt == "" {
+		logger.Warn("VISITOR_SALT is not set, unique visitors are counted again after a restart and on every other instance; set it to the same value on all instances")
+	}
+
+	service := service.NewTraced(serviceDefault)
+
+	trustedProxies, err := app.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Fatal("failed to parse trusted proxies", zap.Error(err))
+	}
+
+	appOpts := []app.Option{
+		app.WithUnlockRateLimit(cfg.UnlockMaxAttempts, cfg.UnlockAttemptWindow),
+		app.WithTrustedProxies(trustedProxies),
+		app.WithMetrics(metrics),
+		app.WithAddr(cfg.HTTPAddr),
+		app.WithTimeouts(cfg.HTTPReadTimeout, cfg.HTTPReadHeaderTimeout, cfg.HTTPWriteTimeout, cfg.HTTPIdleTimeout),
+		app.WithShutdownGracePeriod(cfg.ShutdownGracePeriod),
+	}
+
+	if cfg.HTTPH2C {
+		appOpts = append(appOpts, app.WithH2C())
+	}
+
+	var certs *certreload.Reloader
+	if cfg.TLSCertFile != "" {
+		if certs, err = certreload.New(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			logger.Fatal("failed to load tls certificate", zap.Error(err))
+		}
+		appOpts = append(appOpts, app.WithTLS(certs.GetCertificate))
+	}
+
+	if cfg.InactiveLinkPage != "" {
+		page, err := os.ReadFile(cfg.InactiveLinkPage)
+		if err != nil {
+			logger.Fatal("failed to read inactive link page", zap.Error(err))
+		}
+		appOpts = append(appOpts, app.WithInactivePage(page))
+	}
+
+	admin := app.NewAdmin(logger, cfg.AdminAddr, metrics.Handler(), checker, service)
+
 	router := chi.NewRouter()
-	app := app.NewREST(logger, router, service)
+	app := app.NewREST(logger, router, service, appOpts...)
 
 	app.RegisterRoutes()
 
@@ -84,7 +199,7 @@ func main() {
 	defer cancel()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	defer func() {
 		signal.Stop(c)
 		cancel()
@@ -97,7 +212,94 @@ func main() {
 		}
 	}()
 
+	if certs != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+
+		go func() {
+			for {
+				select {
+				case <-hup:
+					if err := certs.Reload(); err != nil {
+						logger.Error("failed to reload tls certificate", zap.Error(err))
+						continue
+					}
+					logger.Info("reloaded tls certificate")
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// The admin server outlives the app so /readyz reports draining
+	// until the app has finished shutting down.
+	adminCtx, adminCancel := context.WithCancel(context.Background())
+	defer adminCancel()
+
+	go func() {
+		if err := admin.Run(adminCtx); err != nil {
+			logger.Error("failed to run admin server", zap.Error(err))
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		checker.SetDraining()
+	}()
+
 	if err := app.Run(ctx); err != nil {
 		logger.Fatal("failed to run app", zap.Error(err))
 	}
 }
+
+// openDB opens the PostgreSQL database described by cfg.
+func openDB(cfg *config) (*sqlx.DB, error) {
+	return sqlx.Open(postgresDriverName, fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPass, cfg.DBName),
+	)
+}
+
+// newService creates the service configured by cfg. The returned function
+// releases the resources the service uses.
+func newService(logger *zap.Logger, cfg *config, repo repository.Repository) (*service.ServiceDefault, func(), error) {
+	opts := []service.Option{
+		service.WithDefaultRedirectStatus(cfg.DefaultRedirectStatus),
+		service.WithUnlockSecret([]byte(cfg.UnlockSecret)),
+		service.WithUnlockTTL(cfg.UnlockTTL),
+		service.WithVisitorSalt([]byte(cfg.VisitorSalt)),
+	}
+
+	closeService := func() {}
+
+	if cfg.GeoIPDBPath != "" {
+		geoDB, err := geoip.Open(cfg.GeoIPDBPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not open geoip database: %w", err)
+		}
+
+		closeService = func() { geoDB.Close() }
+
+		opts = append(opts, service.WithCountryResolver(geoDB))
+	}
+
+	var botNetworks []*net.IPNet
+	for _, path := range strings.Split(cfg.BotIPRanges, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+
+		networks, err := botdetect.ReadNetworks(path)
+		if err != nil {
+			closeService()
+			return nil, nil, fmt.Errorf("could not read bot ip ranges: %w", err)
+		}
+		botNetworks = append(botNetworks, networks...)
+	}
+
+	opts = append(opts, service.WithBotDetector(botdetect.New(botNetworks...)))
+
+	return service.NewServiceDefault(logger, cfg.AppHost, repo, opts...), closeService, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+)
+
+// migrationLockID keys the PostgreSQL advisory lock held while migrating,
+// so replicas starting at the same time apply migrations one at a time.
+const migrationLockID int64 = 0x75726c74696e79 // "urltiny"
+
+// setupMigrations points goose at the embedded migrations.
+func setupMigrations() error {
+	goose.SetBaseFS(embedMigrations)
+
+	if err := goose.SetDialect(postgresDriverName); err != nil {
+		return fmt.Errorf("could not set goose dialect: %w", err)
+	}
+	return nil
+}
+
+// withMigrationLock runs fn while holding the migration advisory lock,
+// waiting for other processes that hold it to finish first.
+func withMigrationLock(ctx context.Context, db *sqlx.DB, fn func() error) error {
+	// Advisory locks belong to a session, so the lock is taken and
+	// released on the same connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get database connection: %w", err)
+	}
+
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("could not acquire migration lock: %w", err)
+	}
+
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	return fn()
+}
+
+// latestMigrationVersion returns the version of the newest embedded migration.
+func latestMigrationVersion() (int64, error) {
+	migrations, err := goose.CollectMigrations(dbMigrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, fmt.Errorf("could not collect goose migrations: %w", err)
+	}
+
+	latest, err := migrations.Last()
+	if err != nil {
+		return 0, fmt.Errorf("could not find latest goose migration: %w", err)
+	}
+	return latest.Version, nil
+}
+
+// checkSchema returns an error when the database schema is older than the
+// latest embedded migration.
+func checkSchema(db *sql.DB, latest int64) error {
+	version, err := goose.GetDBVersion(db)
+	if err != nil {
+		return fmt.Errorf("could not get migration version: %w", err)
+	}
+
+	if version < latest {
+		return fmt.Errorf("migrations at version %d, want %d", version, latest)
+	}
+	return nil
+}
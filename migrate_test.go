@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	require.NoError(t, setupMigrations())
+
+	files, err := embedMigrations.ReadDir(dbMigrationsDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	// Migrations are numbered sequentially, so the newest is the last file.
+	var expected int64
+	_, err = fmt.Sscanf(files[len(files)-1].Name(), "%d_", &expected)
+	require.NoError(t, err)
+
+	observed, err := latestMigrationVersion()
+	require.NoError(t, err)
+
+	require.Equal(t, expected, observed)
+}
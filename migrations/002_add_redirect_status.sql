@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN redirect_status SMALLINT NOT NULL DEFAULT 302;
+
+-- +goose Down
+ALTER TABLE urls DROP COLUMN redirect_status;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE urls DROP COLUMN created_at;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN password_hash TEXT;
+
+-- +goose Down
+ALTER TABLE urls DROP COLUMN password_hash;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN not_before TIMESTAMPTZ;
+ALTER TABLE urls ADD COLUMN not_after TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE urls DROP COLUMN not_after;
+ALTER TABLE urls DROP COLUMN not_before;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS redirect_rules (
+    id SERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL,
+    condition TEXT NOT NULL,
+    target_url TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_redirect_rules_short_url ON redirect_rules (short_url);
+
+-- +goose Down
+DROP TABLE redirect_rules;
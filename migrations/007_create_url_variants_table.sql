@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN sticky_variants BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS url_variants (
+    id SERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+    target_url TEXT NOT NULL,
+    weight INT NOT NULL CHECK (weight > 0),
+    hits INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_variants_short_url ON url_variants (short_url);
+
+-- Variants are updated by target URL. Target URLs can exceed the size of an
+-- index entry, so their hash is indexed.
+CREATE UNIQUE INDEX IF NOT EXISTS uq_url_variants_target ON url_variants (short_url, md5(target_url));
+
+-- +goose Down
+DROP TABLE url_variants;
+ALTER TABLE urls DROP COLUMN sticky_variants;
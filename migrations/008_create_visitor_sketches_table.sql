@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS visitor_sketches (
+    short_url VARCHAR(255) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    sketch BYTEA NOT NULL,
+    PRIMARY KEY (short_url, day)
+);
+
+-- +goose Down
+DROP TABLE visitor_sketches;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN bot_hits INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE urls DROP COLUMN bot_hits;
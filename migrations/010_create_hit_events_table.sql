@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS hit_events (
+    id BIGSERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    referrer_domain TEXT NOT NULL DEFAULT '',
+    utm_source TEXT NOT NULL DEFAULT '',
+    ua_family TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_hit_events_short_url_occurred_at ON hit_events (short_url, occurred_at);
+
+-- +goose Down
+DROP TABLE hit_events;
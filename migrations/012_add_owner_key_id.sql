@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE urls ADD COLUMN owner_key_id BIGINT REFERENCES api_keys (id) ON DELETE SET NULL;
+CREATE INDEX urls_owner_key_id_created_at_idx ON urls (owner_key_id, created_at);
+
+-- +goose Down
+DROP INDEX urls_owner_key_id_created_at_idx;
+ALTER TABLE urls DROP COLUMN owner_key_id;